@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// admission limits how many scrape requests the server runs at once
+// (-serve-max-concurrent) and how many may wait for a slot
+// (-serve-queue-size). Requests beyond both are turned away so load sheds
+// at the door instead of piling up worker pools. A zero maxConcurrent
+// disables the limit.
+type admission struct {
+	maxConcurrent int
+	queueSize     int
+	slots         chan struct{} // held while a request runs
+	tickets       chan struct{} // held while a request runs or waits
+
+	active   int64
+	queued   int64
+	served   int64
+	rejected int64
+}
+
+func newAdmission(maxConcurrent, queueSize int) *admission {
+	a := &admission{maxConcurrent: maxConcurrent, queueSize: queueSize}
+	if maxConcurrent > 0 {
+		a.slots = make(chan struct{}, maxConcurrent)
+		a.tickets = make(chan struct{}, maxConcurrent+queueSize)
+	}
+	return a
+}
+
+// acquire waits for a slot. It fails at once when the queue is full, or
+// when ctx ends while waiting; on success release must be called.
+func (a *admission) acquire(ctx context.Context) (release func(), ok bool) {
+	done := func() {
+		atomic.AddInt64(&a.active, -1)
+		atomic.AddInt64(&a.served, 1)
+	}
+	if a.slots == nil {
+		atomic.AddInt64(&a.active, 1)
+		return done, true
+	}
+
+	select {
+	case a.tickets <- struct{}{}:
+	default:
+		atomic.AddInt64(&a.rejected, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&a.queued, 1)
+	select {
+	case a.slots <- struct{}{}:
+		atomic.AddInt64(&a.queued, -1)
+	case <-ctx.Done():
+		atomic.AddInt64(&a.queued, -1)
+		<-a.tickets
+		return nil, false
+	}
+
+	atomic.AddInt64(&a.active, 1)
+	return func() {
+		done()
+		<-a.slots
+		<-a.tickets
+	}, true
+}
+
+// serverMetrics is the body of GET /metrics
+type serverMetrics struct {
+	ActiveRequests int64 `json:"active_requests"`
+	QueueDepth     int64 `json:"queue_depth"`
+	MaxConcurrent  int   `json:"max_concurrent"` // 0 = unlimited
+	QueueSize      int   `json:"queue_size"`
+	ServedTotal    int64 `json:"served_total"`
+	RejectedTotal  int64 `json:"rejected_total"`
+}
+
+func (a *admission) metrics() serverMetrics {
+	return serverMetrics{
+		ActiveRequests: atomic.LoadInt64(&a.active),
+		QueueDepth:     atomic.LoadInt64(&a.queued),
+		MaxConcurrent:  a.maxConcurrent,
+		QueueSize:      a.queueSize,
+		ServedTotal:    atomic.LoadInt64(&a.served),
+		RejectedTotal:  atomic.LoadInt64(&a.rejected),
+	}
+}
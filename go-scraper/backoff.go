@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// backoffDelay returns how long to wait before the given retry (1 = first
+// retry). The base delay doubles per retry up to maxDelay, then jitterFactor
+// decides how much of that delay is randomized:
+//
+//	delay = exp*(1-jitterFactor) + rand[0, exp*jitterFactor)
+//
+// This covers the AWS Architecture Blog jitter algorithms:
+//
+//	jitterFactor 0.0  no jitter, deterministic exponential backoff
+//	jitterFactor 0.5  "Equal Jitter": exp/2 + rand[0, exp/2)
+//	jitterFactor 1.0  "Full Jitter": rand[0, exp)
+//
+// Values in between trade predictability against decorrelated retries.
+func backoffDelay(retry int, base time.Duration, maxDelay time.Duration, jitterFactor float64) time.Duration {
+	if base <= 0 || retry <= 0 {
+		return 0
+	}
+
+	exp := base
+	for i := 1; i < retry && exp < maxDelay; i++ {
+		exp *= 2
+	}
+	if maxDelay > 0 && exp > maxDelay {
+		exp = maxDelay
+	}
+
+	fixed := float64(exp) * (1 - jitterFactor)
+	random := rand.Float64() * float64(exp) * jitterFactor
+	return time.Duration(fixed + random)
+}
+
+// sleepContext waits for d or until ctx is cancelled, reporting whether the
+// full delay elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
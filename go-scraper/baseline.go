@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Baseline change monitoring (-baseline-dir)
+//
+// The baseline directory holds one body per URL, named by the SHA-256 of the
+// URL so any URL maps to a safe, fixed-length file name. Each successful
+// result is compared byte for byte with its saved body and marked "new",
+// "changed" or "unchanged"; failed fetches are left alone so an outage is not
+// reported as a change. -update-baseline then saves the fetched bodies.
+
+// BaselineSummary counts baseline statuses across a run
+type BaselineSummary struct {
+	Changed     int      `json:"changed"`
+	Unchanged   int      `json:"unchanged"`
+	New         int      `json:"new"`
+	Skipped     int      `json:"skipped"` // failed fetches, not compared
+	ChangedURLs []string `json:"changed_urls,omitempty"`
+	Updated     bool     `json:"updated"`
+}
+
+// maxDiffCells caps the lines(old) x lines(new) table of the LCS diff
+const maxDiffCells = 4 << 20
+
+func baselinePath(dir, targetURL string) string {
+	return filepath.Join(dir, contentSHA256([]byte(targetURL))+".body")
+}
+
+// compareBaseline sets Baseline (and BaselineDiff when withDiff) on each
+// result and optionally rewrites the saved bodies
+func compareBaseline(results []Result, dir string, update, withDiff bool) (*BaselineSummary, error) {
+	if update {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	summary := &BaselineSummary{Updated: update}
+	for i := range results {
+		result := &results[i]
+		if !result.Success {
+			summary.Skipped++
+			continue
+		}
+
+		path := baselinePath(dir, result.URL)
+		saved, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			result.Baseline = "new"
+			summary.New++
+		case err != nil:
+			return nil, err
+		case string(saved) == result.Content:
+			result.Baseline = "unchanged"
+			summary.Unchanged++
+		default:
+			result.Baseline = "changed"
+			summary.Changed++
+			summary.ChangedURLs = append(summary.ChangedURLs, result.URL)
+			if withDiff {
+				result.BaselineDiff = unifiedDiff(string(saved), result.Content, "baseline", "current")
+			}
+		}
+
+		if update && result.Baseline != "unchanged" {
+			if err := os.WriteFile(path, []byte(result.Content), 0o644); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return summary, nil
+}
+
+// unifiedDiff returns a line-based unified diff with three lines of context,
+// computed from the longest common subsequence
+func unifiedDiff(oldText, newText, oldName, newName string) string {
+	a, b := splitLines(oldText), splitLines(newText)
+	if len(a)*len(b) > maxDiffCells {
+		return fmt.Sprintf("diff omitted: %d x %d lines exceeds the diff size limit\n", len(a), len(b))
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table into an edit script of ' ', '-' and '+' lines
+	type edit struct {
+		op         byte
+		line       string
+		oldN, newN int // 1-based line numbers before this edit
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+
+	const contextLines = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+
+		// Extend the hunk until a run of more than 2*context unchanged lines
+		start := k - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*contextLines {
+				end += contextLines
+				if end > len(edits) {
+					end = len(edits)
+				}
+				break
+			}
+			end = run
+		}
+
+		oldCount, newCount := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", edits[start].oldN, oldCount, edits[start].newN, newCount)
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// splitLines splits text after each newline, without the empty element a
+// trailing newline would leave
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
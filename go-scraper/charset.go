@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset transcoding
+//
+// Bodies are converted to UTF-8 when either a per-host override applies
+// (-charset-overrides) or -transcode is set, and only when the response
+// declares a text media type (text/*, *+xml, application/json,
+// application/xhtml+xml). Images, archives and untyped bodies are never
+// touched, so -base64-binary still sees their raw bytes. The source charset
+// is chosen with this precedence:
+//
+//  1. the override for the original URL's host, for servers that
+//     consistently mislabel their pages
+//  2. the charset parameter of the Content-Type header
+//  3. sniffing: a <meta> charset declaration near the top of the body, else
+//     utf-8 when the body is valid UTF-8, else windows-1252
+//
+// Only utf-8, iso-8859-1 and windows-1252 (plus their common aliases) can be
+// decoded; other charsets leave the body untouched.
+
+// charsetPolicy holds the per-host overrides and whether bodies without one
+// are transcoded too. A nil policy transcodes nothing.
+type charsetPolicy struct {
+	overrides map[string]string // host -> canonical charset
+	auto      bool
+}
+
+func newCharsetPolicy(overrideSpec string, auto bool) (*charsetPolicy, error) {
+	rules, err := parseHostRules(overrideSpec)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]string, len(rules))
+	for host, label := range rules {
+		charset := canonicalCharset(label)
+		if _, ok := decoders[charset]; !ok {
+			return nil, fmt.Errorf("unsupported charset %q for %s, expected utf-8, iso-8859-1 or windows-1252", label, host)
+		}
+		overrides[host] = charset
+	}
+	if len(overrides) == 0 && !auto {
+		return nil, nil
+	}
+	return &charsetPolicy{overrides: overrides, auto: auto}, nil
+}
+
+// detect picks the source charset of body and says where it came from
+// ("override", "header" or "sniff"). It returns "" when no transcoding applies.
+func (p *charsetPolicy) detect(targetURL, contentType string, body []byte) (charset, source string) {
+	if p == nil {
+		return "", ""
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !isTextMediaType(mediaType) {
+		return "", ""
+	}
+	if u, err := url.Parse(targetURL); err == nil {
+		if charset, ok := matchHost(p.overrides, u.Hostname()); ok {
+			return charset, "override"
+		}
+	}
+	if !p.auto {
+		return "", ""
+	}
+	if params["charset"] != "" {
+		return canonicalCharset(params["charset"]), "header"
+	}
+	return sniffCharset(body), "sniff"
+}
+
+// isTextMediaType reports whether a media type carries text worth decoding
+func isTextMediaType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	case mediaType == "application/json", mediaType == "application/xhtml+xml":
+		return true
+	}
+	return false
+}
+
+// metaCharset matches <meta charset="x"> and
+// <meta http-equiv="Content-Type" content="text/html; charset=x">
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_.:-]+)`)
+
+// sniffCharset is only used for bodies already declared as text, so the
+// windows-1252 fallback never applies to binary assets
+func sniffCharset(body []byte) string {
+	if utf8.Valid(body) {
+		return "utf-8"
+	}
+	head := body
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if m := metaCharset.FindSubmatch(head); m != nil {
+		return canonicalCharset(string(m[1]))
+	}
+	return "windows-1252"
+}
+
+// canonicalCharset maps common labels onto the names decoders knows
+func canonicalCharset(label string) string {
+	switch label = strings.ToLower(strings.Trim(strings.TrimSpace(label), `"'`)); label {
+	case "utf8", "unicode-1-1-utf-8", "us-ascii", "ascii":
+		return "utf-8"
+	case "latin1", "latin-1", "iso8859-1", "iso_8859-1", "l1":
+		return "iso-8859-1"
+	case "cp1252", "x-cp1252", "windows1252":
+		return "windows-1252"
+	}
+	return label
+}
+
+// decoders convert a body in the named charset to UTF-8
+var decoders = map[string]func([]byte) []byte{
+	"utf-8":        func(b []byte) []byte { return b },
+	"iso-8859-1":   func(b []byte) []byte { return decodeSingleByte(b, nil) },
+	"windows-1252": func(b []byte) []byte { return decodeSingleByte(b, &windows1252High) },
+}
+
+// windows1252High maps bytes 0x80-0x9F, where windows-1252 differs from
+// iso-8859-1. The five unassigned bytes map to the same C1 control as in
+// iso-8859-1.
+var windows1252High = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+func decodeSingleByte(body []byte, high *[32]rune) []byte {
+	out := make([]byte, 0, len(body)+len(body)/4)
+	for _, c := range body {
+		switch {
+		case c < 0x80:
+			out = append(out, c)
+		case high != nil && c < 0xA0:
+			out = utf8.AppendRune(out, high[c-0x80])
+		default:
+			out = utf8.AppendRune(out, rune(c))
+		}
+	}
+	return out
+}
+
+// transcode converts body to UTF-8 from charset. ok is false for charsets
+// that cannot be decoded, in which case body is returned unchanged.
+func transcode(body []byte, charset string) (decoded []byte, ok bool) {
+	decode, ok := decoders[charset]
+	if !ok {
+		return body, false
+	}
+	return decode(body), true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// ProxyTypeStats summarizes one proxy type in -compare-proxy-types mode
+type ProxyTypeStats struct {
+	ProxyType          string  `json:"proxy_type"`
+	Proxies            int     `json:"proxies"`
+	URLs               int     `json:"urls"`
+	Successful         int     `json:"successful"`
+	SuccessRate        float64 `json:"success_rate"`
+	AvgElapsedSeconds  float64 `json:"avg_elapsed_seconds"`
+	P50ElapsedSeconds  float64 `json:"p50_elapsed_seconds"`
+	MaxElapsedSeconds  float64 `json:"max_elapsed_seconds"`
+	AvgAttempts        float64 `json:"avg_attempts"`
+	OnlySuccessfulHere int     `json:"only_successful_here"` // URLs that failed through every other type
+}
+
+// proxiesByType splits the pool on each proxy's #type= annotation, with
+// unannotated proxies counted as -proxy-type
+func proxiesByType(proxies []string, defaultType string) map[string][]string {
+	pools := make(map[string][]string)
+	for _, p := range proxies {
+		proxyType := strings.ToLower(defaultType)
+		if _, opts, err := parseProxy(p); err == nil && opts.Type != "" {
+			proxyType = opts.Type
+		}
+		pools[proxyType] = append(pools[proxyType], p)
+	}
+	return pools
+}
+
+// compareProxyTypes scrapes every sampled URL once through each proxy type's
+// pool and returns all results, each with ProxyUsed set to its type, plus
+// per-type stats.
+//
+// Sampling: with sample > 0, that many URLs are picked at random from targets
+// and the rest are not fetched at all; 0 compares every URL. Types run one
+// after another, each with the full -concurrency, so they do not compete for
+// bandwidth and their latencies stay comparable.
+func compareProxyTypes(ctx context.Context, targets []scrapeTarget, proxies []string, cfg scrapeConfig, sample int) ([]Result, []ProxyTypeStats, error) {
+	pools := proxiesByType(proxies, cfg.ProxyType)
+	if len(pools) < 2 {
+		return nil, nil, fmt.Errorf("-compare-proxy-types needs proxies of at least two types, annotate them with #type=")
+	}
+	types := make([]string, 0, len(pools))
+	for proxyType := range pools {
+		types = append(types, proxyType)
+	}
+	sort.Strings(types)
+
+	if sample > 0 && sample < len(targets) {
+		sampled := make([]scrapeTarget, 0, sample)
+		for _, i := range rand.Perm(len(targets))[:sample] {
+			sampled = append(sampled, targets[i])
+		}
+		targets = sampled
+	}
+
+	var all []Result
+	byType := make(map[string][]Result, len(types))
+	for _, proxyType := range types {
+		typeCfg := cfg
+		typeCfg.ProxyType = proxyType
+		logf("Comparing %s: %d URLs through %d proxies", proxyType, len(targets), len(pools[proxyType]))
+		results := scrapeURLs(ctx, targets, pools[proxyType], typeCfg, nil)
+		byType[proxyType] = results
+		all = append(all, results...)
+	}
+
+	// URL -> number of types it succeeded through
+	successes := make(map[string]int)
+	for _, result := range all {
+		if result.Success {
+			successes[result.URL]++
+		}
+	}
+
+	stats := make([]ProxyTypeStats, 0, len(types))
+	for _, proxyType := range types {
+		s := ProxyTypeStats{ProxyType: proxyType, Proxies: len(pools[proxyType])}
+		var elapsed []float64
+		attempts := 0
+		for _, result := range byType[proxyType] {
+			s.URLs++
+			if result.Success {
+				s.Successful++
+				if successes[result.URL] == 1 {
+					s.OnlySuccessfulHere++
+				}
+			}
+			elapsed = append(elapsed, result.ElapsedTime)
+			attempts += result.AttemptsMade
+		}
+		if s.URLs > 0 {
+			sort.Float64s(elapsed)
+			total := 0.0
+			for _, e := range elapsed {
+				total += e
+			}
+			s.SuccessRate = float64(s.Successful) / float64(s.URLs)
+			s.AvgElapsedSeconds = total / float64(s.URLs)
+			s.P50ElapsedSeconds = elapsed[len(elapsed)/2]
+			s.MaxElapsedSeconds = elapsed[len(elapsed)-1]
+			s.AvgAttempts = float64(attempts) / float64(s.URLs)
+		}
+		stats = append(stats, s)
+	}
+	return all, stats, nil
+}
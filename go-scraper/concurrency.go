@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Concurrency heuristic
+//
+// Scraping is I/O bound, so the worker pool is sized well above the CPU
+// count, but it is still tied to GOMAXPROCS so that a container limited to a
+// couple of CPUs does not spawn thousands of goroutines that mostly compete
+// for the scheduler, TLS handshakes and JSON encoding. With -concurrency unset
+// the pool size is GOMAXPROCS * -concurrency-per-cpu.
+//
+// Go runtimes before 1.25 set GOMAXPROCS from the host's CPU count and ignore
+// cgroup CPU quotas, so under `docker run --cpus=2` on a 64-core host the pool
+// would be sized for 64 CPUs. -auto-maxprocs reads the cgroup quota (v2
+// cpu.max, falling back to v1 cpu.cfs_quota_us) and lowers GOMAXPROCS to
+// ceil(quota/period) first, in the spirit of uber-go/automaxprocs.
+
+// resolveConcurrency returns the worker count for a run
+func resolveConcurrency(concurrency int, perCPU int) int {
+	if concurrency > 0 {
+		return concurrency
+	}
+	if perCPU < 1 {
+		perCPU = 1
+	}
+	return runtime.GOMAXPROCS(0) * perCPU
+}
+
+// applyCgroupCPULimit lowers GOMAXPROCS to the container CPU quota and
+// reports the new value, or 0 when no quota applies
+func applyCgroupCPULimit() int {
+	quota, period, ok := readCgroupCPUQuota()
+	if !ok || quota <= 0 || period <= 0 {
+		return 0
+	}
+	procs := int(math.Ceil(float64(quota) / float64(period)))
+	if procs < 1 {
+		procs = 1
+	}
+	if procs >= runtime.GOMAXPROCS(0) {
+		return 0
+	}
+	runtime.GOMAXPROCS(procs)
+	return procs
+}
+
+func readCgroupCPUQuota() (int64, int64, bool) {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, 0, false
+		}
+		quota, err1 := strconv.ParseInt(fields[0], 10, 64)
+		period, err2 := strconv.ParseInt(fields[1], 10, 64)
+		return quota, period, err1 == nil && err2 == nil
+	}
+
+	// cgroup v1: quota of -1 means unlimited
+	quotaData, err1 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	periodData, err2 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	quota, err1 := strconv.ParseInt(strings.TrimSpace(string(quotaData)), 10, 64)
+	period, err2 := strconv.ParseInt(strings.TrimSpace(string(periodData)), 10, 64)
+	return quota, period, err1 == nil && err2 == nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Cookie is a structured Set-Cookie line
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain,omitempty"`
+	Path     string     `json:"path,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	MaxAge   int        `json:"max_age,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HttpOnly bool       `json:"http_only,omitempty"`
+	SameSite string     `json:"same_site,omitempty"`
+}
+
+// parseSetCookies parses raw Set-Cookie lines, skipping malformed ones
+func parseSetCookies(lines []string) []Cookie {
+	resp := &http.Response{Header: http.Header{"Set-Cookie": lines}}
+	var cookies []Cookie
+	for _, c := range resp.Cookies() {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			MaxAge:   c.MaxAge,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: sameSiteName(c.SameSite),
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies
+}
+
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectCookiesRecordedOnceWithHeadProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "tok"})
+		http.Redirect(w, r, "/home", http.StatusFound)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "home")
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+
+	cfg := scrapeConfig{Timeout: 5, MaxRetries: 1, ProbeHeadFirst: true, CaptureCookies: true, ParseCookies: true}
+	result := scrapeURL(context.Background(), origin.URL+"/login", nil, cfg)
+	if !result.Success {
+		t.Fatalf("scrape failed: %s", result.Error)
+	}
+	if len(result.SetCookies) != 1 || result.SetCookies[0] != "session=tok" {
+		t.Fatalf("set_cookies = %q, want [session=tok]", result.SetCookies)
+	}
+	if len(result.Cookies) != 1 {
+		t.Fatalf("parsed %d cookies, want 1", len(result.Cookies))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// dialSettings carries per-attempt dial behaviour through the request context
+// to the shared transports' DialContext. The transport may dial on its own
+// goroutine, possibly outliving a timed-out request, so notes are buffered
+// under a lock and merged into the attempt log once the request returns.
+type dialSettings struct {
+	retries int
+	timeout time.Duration // per dial, 0 = the dialer's default
+
+	mu    sync.Mutex
+	notes []string
+}
+
+type dialSettingsKey struct{}
+
+// withDialSettings attaches dial settings for one attempt to ctx
+func withDialSettings(ctx context.Context, retries int, timeout time.Duration) (context.Context, *dialSettings) {
+	settings := &dialSettings{retries: retries, timeout: timeout}
+	return context.WithValue(ctx, dialSettingsKey{}, settings), settings
+}
+
+func (s *dialSettings) note(format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notes = append(s.notes, fmt.Sprintf(format, args...))
+}
+
+// drain returns and clears the buffered notes
+func (s *dialSettings) drain() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notes := s.notes
+	s.notes = nil
+	return notes
+}
+
+// retryingDialer re-dials failed TCP connections up to the attempt's
+// -dial-retries before giving up, so a transient connect failure does not
+// burn a whole request attempt
+func retryingDialer(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		settings, _ := ctx.Value(dialSettingsKey{}).(*dialSettings)
+
+		retries := 0
+		var timeout time.Duration
+		if settings != nil {
+			retries, timeout = settings.retries, settings.timeout
+		}
+
+		var lastErr error
+		for try := 0; try <= retries; try++ {
+			if try > 0 {
+				settings.note("Dial retry %d/%d to %s after: %v", try, retries, addr, lastErr)
+				logf("dial retry %d/%d to %s after: %v", try, retries, addr, lastErr)
+			}
+			dialStart := time.Now()
+			conn, err := dialWithTimeout(ctx, dialer, network, addr, timeout)
+			if err == nil {
+				if try > 0 {
+					settings.note("Dial to %s succeeded on retry %d after %s", addr, try, time.Since(dialStart))
+				}
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
+
+func dialWithTimeout(ctx context.Context, dialer *net.Dialer, network, addr string, timeout time.Duration) (net.Conn, error) {
+	if timeout <= 0 {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return dialer.DialContext(dialCtx, network, addr)
+}
+
+// dialTimeoutFor escalates the connect timeout across request attempts,
+// doubling from base on each retry up to maxTimeout: a fast-failing first
+// attempt stays quick, while later attempts give a slow path time to connect.
+// A zero base leaves the dialer's default in place.
+func dialTimeoutFor(attempt int, base, maxTimeout time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	timeout := base
+	for i := 0; i < attempt && (maxTimeout <= 0 || timeout < maxTimeout); i++ {
+		timeout *= 2
+	}
+	if maxTimeout > 0 && timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	return timeout
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// contentSHA256 returns the hex SHA-256 of a response body
+func contentSHA256(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// runFingerprint hashes the sorted (url, status, content_sha256) tuples of a
+// run. Timing and result order do not contribute, so two runs against
+// unchanged targets produce the same fingerprint.
+func runFingerprint(results []Result) string {
+	tuples := make([]string, 0, len(results))
+	for _, result := range results {
+		tuples = append(tuples, fmt.Sprintf("%s\t%d\t%s", result.URL, result.StatusCode, result.ContentSHA256))
+	}
+	sort.Strings(tuples)
+
+	sum := sha256.Sum256([]byte(strings.Join(tuples, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Goroutine cap (-max-goroutines)
+//
+// Background goroutines started by the scraper itself (workers, streams,
+// mirror fetches) take a slot from one shared semaphore. When none is free
+// the caller does the work inline instead of waiting, which keeps nested
+// users such as a mirror run starting its own worker pool from
+// deadlocking; the run just gets less parallel. Goroutines owned by net/http
+// are not counted.
+
+var goroutineCap struct {
+	limit  int64
+	slots  chan struct{} // nil = unlimited
+	inUse  int64
+	warned int32
+}
+
+// setGoroutineCap installs the cap; 0 disables it
+func setGoroutineCap(limit int) {
+	goroutineCap.limit = int64(limit)
+	goroutineCap.slots = nil
+	if limit > 0 {
+		goroutineCap.slots = make(chan struct{}, limit)
+	}
+}
+
+// tryGo starts fn on a new goroutine if the cap allows and reports whether
+// it did. Callers run fn themselves when it returns false.
+func tryGo(fn func()) bool {
+	if goroutineCap.slots == nil {
+		go fn()
+		return true
+	}
+
+	select {
+	case goroutineCap.slots <- struct{}{}:
+	default:
+		return false
+	}
+
+	// Warn once when 90% of the cap is in use, re-arm below 75%
+	inUse := atomic.AddInt64(&goroutineCap.inUse, 1)
+	if inUse*10 >= goroutineCap.limit*9 && atomic.CompareAndSwapInt32(&goroutineCap.warned, 0, 1) {
+		logf("warning: %d of %d background goroutines in use (%d goroutines total)", inUse, goroutineCap.limit, runtime.NumGoroutine())
+	}
+
+	go func() {
+		defer func() {
+			if atomic.AddInt64(&goroutineCap.inUse, -1)*4 < goroutineCap.limit*3 {
+				atomic.StoreInt32(&goroutineCap.warned, 0)
+			}
+			<-goroutineCap.slots
+		}()
+		fn()
+	}()
+	return true
+}
+
+// goroutineStats is the goroutine part of GET /metrics
+type goroutineStats struct {
+	Goroutines      int   `json:"goroutines"`
+	BackgroundInUse int64 `json:"background_in_use"`
+	MaxGoroutines   int64 `json:"max_goroutines"` // 0 = unlimited
+}
+
+func currentGoroutineStats() goroutineStats {
+	return goroutineStats{
+		Goroutines:      runtime.NumGoroutine(),
+		BackgroundInUse: atomic.LoadInt64(&goroutineCap.inUse),
+		MaxGoroutines:   goroutineCap.limit,
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// scrapeTarget is a URL to scrape plus optional per-URL overrides of the
+// global settings. Nil overrides fall back to the run-wide flags.
+type scrapeTarget struct {
+	URL        string `json:"url"`
+	Timeout    *int   `json:"timeout,omitempty"`
+	MaxRetries *int   `json:"max_retries,omitempty"`
+}
+
+// apply returns a copy of cfg with the target's overrides applied
+func (t scrapeTarget) apply(cfg scrapeConfig) scrapeConfig {
+	if t.Timeout != nil {
+		cfg.Timeout = *t.Timeout
+		cfg.TimeoutPinned = true
+	}
+	if t.MaxRetries != nil {
+		cfg.MaxRetries = *t.MaxRetries
+	}
+	return cfg
+}
+
+// loadTargets reads JSONL input, one object per line:
+//
+//	{"url": "https://example.com/slow", "timeout": 30, "max_retries": 5}
+//
+// Blank lines are skipped. A path of "-" reads from stdin.
+func loadTargets(path string) ([]scrapeTarget, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var targets []scrapeTarget
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		t, err := parseTarget(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		targets = append(targets, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// parseTarget decodes and validates one JSON target object
+func parseTarget(line string) (scrapeTarget, error) {
+	var t scrapeTarget
+	if err := json.Unmarshal([]byte(line), &t); err != nil {
+		return t, err
+	}
+	t.URL = strings.TrimSpace(t.URL)
+	if t.URL == "" {
+		return t, fmt.Errorf("missing \"url\"")
+	}
+	if t.Timeout != nil && *t.Timeout < 1 {
+		return t, fmt.Errorf("timeout must be at least 1 second")
+	}
+	if t.MaxRetries != nil && *t.MaxRetries < 1 {
+		return t, fmt.Errorf("max_retries must be at least 1")
+	}
+	return t, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// JUnit XML report (-junit-out) for CI health checks: one testcase per URL,
+// failed when the Result is not successful
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// writeJUnitReport writes response as JUnit XML to path. Test cases are
+// grouped under their host as the classname so CI UIs cluster them.
+func writeJUnitReport(path string, response Response) error {
+	suite := junitTestSuite{
+		Name:     "fast-scraper",
+		Tests:    response.Total,
+		Failures: response.Failed,
+		Time:     fmt.Sprintf("%.3f", response.TotalTimeSeconds),
+	}
+	for _, result := range response.Results {
+		tc := junitTestCase{
+			Name:      result.URL,
+			ClassName: result.URL,
+			Time:      fmt.Sprintf("%.3f", result.ElapsedTime),
+		}
+		if u, err := url.Parse(result.URL); err == nil && u.Host != "" {
+			tc.ClassName = u.Host
+		}
+		if !result.Success {
+			message := result.Error
+			if message == "" {
+				message = fmt.Sprintf("unexpected status %d", result.StatusCode)
+			}
+			tc.Failure = &junitFailure{
+				Message: message,
+				Type:    fmt.Sprintf("status_%d", result.StatusCode),
+				Text:    fmt.Sprintf("status: %d %s\nattempts: %d\n\n%s", result.StatusCode, result.StatusText, result.AttemptsMade, result.DetailedError),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0o644)
+}
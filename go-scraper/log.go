@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// logOutput receives every non-fatal diagnostic; -quiet swaps it for
+// io.Discard. Fatal errors bypass it and always reach stderr.
+var logOutput io.Writer = os.Stderr
+
+// logf writes a diagnostic line to stderr, keeping stdout clean for JSON
+func logf(format string, args ...any) {
+	fmt.Fprintf(logOutput, format+"\n", args...)
+}
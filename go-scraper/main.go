@@ -1,44 +1,141 @@
 package main
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
+	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // Result represents a single URL scraping result
 type Result struct {
-	URL             string            `json:"url"`
-	StatusCode      int               `json:"status_code,omitempty"`
-	Content         string            `json:"content,omitempty"`
-	Error           string            `json:"error,omitempty"`
-	DetailedError   string            `json:"detailed_error,omitempty"`
-	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
-	FinalURL        string            `json:"final_url,omitempty"`
-	ElapsedTime     float64           `json:"elapsed_seconds"`
-	Success         bool              `json:"success"`
-	ProxyUsed       string            `json:"proxy_used"`
-	AttemptsMade    int               `json:"attempts_made"`
+	URL                        string            `json:"url"`
+	StatusCode                 int               `json:"status_code,omitempty"`
+	StatusText                 string            `json:"status_text,omitempty"` // reason phrase as sent, e.g. "OK"
+	Content                    string            `json:"content,omitempty"`
+	Error                      string            `json:"error,omitempty"`
+	DetailedError              string            `json:"detailed_error,omitempty"`
+	ResponseHeaders            map[string]string `json:"response_headers,omitempty"`
+	FinalURL                   string            `json:"final_url,omitempty"`
+	ElapsedTime                float64           `json:"elapsed_seconds"`
+	Success                    bool              `json:"success"`
+	ProxyUsed                  string            `json:"proxy_used"`
+	AttemptsMade               int               `json:"attempts_made"`
+	TLS                        *TLSInfo          `json:"tls,omitempty"`
+	HostHeader                 string            `json:"host_header,omitempty"`
+	BytesRead                  int64             `json:"bytes_read"`
+	Truncated                  bool              `json:"truncated,omitempty"`
+	ContentSHA256              string            `json:"content_sha256,omitempty"`
+	SetCookies                 []string          `json:"set_cookies,omitempty"`
+	Cookies                    []Cookie          `json:"cookies,omitempty"`
+	ContentBinary              bool              `json:"content_binary,omitempty"`
+	ContentEncoding            string            `json:"content_encoding,omitempty"`
+	Charset                    string            `json:"charset,omitempty"` // source charset of a transcoded body
+	CharsetSource              string            `json:"charset_source,omitempty"`
+	EffectiveRequest           *EffectiveRequest `json:"effective_request,omitempty"`
+	BlockedRedirect            string            `json:"blocked_redirect,omitempty"`
+	DecompressionLimitExceeded bool              `json:"decompression_limit_exceeded,omitempty"`
+	Baseline                   string            `json:"baseline,omitempty"` // new, changed or unchanged with -baseline-dir
+	BaselineDiff               string            `json:"baseline_diff,omitempty"`
+	Attempts                   []AttemptRecord   `json:"-"` // flattened into Response.Attempts by -emit-attempts
 }
 
 // Response represents the overall response from the scraper
 type Response struct {
-	Results          []Result `json:"results"`
-	Total            int      `json:"total"`
-	Successful       int      `json:"successful"`
-	Failed           int      `json:"failed"`
-	TotalTimeSeconds float64  `json:"total_time_seconds"`
-	ProxyTypeUsed    string   `json:"proxy_type_used"`
+	Results            []Result            `json:"results"`
+	Total              int                 `json:"total"`
+	Successful         int                 `json:"successful"`
+	Failed             int                 `json:"failed"`
+	TotalTimeSeconds   float64             `json:"total_time_seconds"`
+	ProxyTypeUsed      string              `json:"proxy_type_used"`
+	CertExpiryWarnings []CertExpiryWarning `json:"cert_expiry_warnings,omitempty"`
+	RetryEffectiveness RetryEffectiveness  `json:"retry_effectiveness"`
+	RunFingerprint     string              `json:"run_fingerprint,omitempty"`
+	Attempts           []AttemptRecord     `json:"attempts,omitempty"`
+	ProxyComparison    []ProxyTypeStats    `json:"proxy_comparison,omitempty"`
+	Baseline           *BaselineSummary    `json:"baseline,omitempty"`
+	Mirror             *MirrorSummary      `json:"mirror,omitempty"`
+}
+
+// AttemptRecord describes a single request attempt for a URL
+type AttemptRecord struct {
+	URL            string    `json:"url"`
+	Attempt        int       `json:"attempt"`
+	Method         string    `json:"method"`
+	StartedAt      time.Time `json:"started_at"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	Proxy          string    `json:"proxy,omitempty"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	BytesRead      int64     `json:"bytes_read,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Success        bool      `json:"success"`
+
+	proxyKey string // bareProxy of the proxy used, unmasked; never exported
+}
+
+// RetryEffectiveness shows whether retries paid off across a run
+type RetryEffectiveness struct {
+	FirstAttemptSuccesses int `json:"first_attempt_successes"` // succeeded without retrying
+	RetrySuccesses        int `json:"retry_successes"`         // succeeded only because of a retry
+	RetriesMade           int `json:"retries_made"`            // attempts beyond the first, across all URLs
+	UsefulRetries         int `json:"useful_retries"`          // retries spent on URLs that eventually succeeded
+	WastedRetries         int `json:"wasted_retries"`          // retries spent on URLs that failed anyway
+}
+
+// scrapeConfig holds the settings shared by every URL in a run
+type scrapeConfig struct {
+	ProxyType         string
+	Timeout           int
+	TimeoutPinned     bool // Timeout came from a per-URL override and beats proxy timeouts
+	ProxyTypeTimeouts map[string]int
+	MaxRetries        int
+	ProbeHeadFirst    bool
+	ProbeMaxBytes     int64
+	Render            bool
+	BrowserWS         string
+	CaptureTLS        bool
+	HostHeader        string
+	BackoffBase       time.Duration
+	BackoffMax        time.Duration
+	JitterFactor      float64
+	MaxURLBytes       int64
+	CertWarnDays      int
+	HashContent       bool
+	Concurrency       int
+	RetryTruncated    bool
+	Success           *successPolicy
+	CaptureCookies    bool
+	ParseCookies      bool
+	Base64Binary      bool
+	MaxPerProxy       int
+	DialRetries       int
+	DialTimeout       time.Duration // first attempt's connect timeout, doubled per retry
+	DialTimeoutMax    time.Duration
+	MaxDecompressed   int64
+	RetryOnHostChange bool
+	Charsets          *charsetPolicy
+	Redirects         *redirectPolicy
+}
+
+// isSuccess judges a status code for a URL, defaulting to any 2xx
+func (cfg scrapeConfig) isSuccess(targetURL string, statusCode int) bool {
+	if cfg.Success == nil {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return cfg.Success.isSuccess(targetURL, statusCode)
 }
 
 var userAgents = []string{
@@ -50,27 +147,113 @@ var userAgents = []string{
 func main() {
 	// Parse command line arguments
 	urlsFlag := flag.String("urls", "", "Comma-separated list of URLs to scrape")
-	proxiesFlag := flag.String("proxies", "", "Comma-separated list of proxies to use")
+	proxiesFlag := flag.String("proxies", "", "Comma-separated list of proxies to use; each may carry a #type=...&timeout=... annotation")
 	proxyTypeFlag := flag.String("proxy-type", "datacenter", "Type of proxy (datacenter, residential, etc.)")
-	timeoutFlag := flag.Int("timeout", 5, "Timeout in seconds for each request")
+	timeoutFlag := flag.Int("timeout", 5, "Timeout in seconds for each request, unless overridden per URL or per proxy")
 	maxRetriesFlag := flag.Int("max-retries", 1, "Maximum number of retries for each URL")
+	probeHeadFirstFlag := flag.Bool("probe-head-first", false, "Issue a HEAD request first and only GET when the status is OK and the size is under -probe-max-bytes")
+	probeMaxBytesFlag := flag.Int64("probe-max-bytes", 10<<20, "Largest Content-Length reported by the HEAD probe that is still fetched (0 = no limit)")
+	renderFlag := flag.Bool("render", false, "Fetch pages through a headless browser (requires -browser-ws) and return the rendered HTML")
+	browserWSFlag := flag.String("browser-ws", "", "Chrome DevTools endpoint: ws://host:9222/devtools/browser/<id> or http://host:9222")
+	captureTLSFlag := flag.Bool("capture-tls", false, "Record the negotiated TLS version, cipher suite and ALPN protocol for HTTPS requests")
+	inputFileFlag := flag.String("input-file", "", "JSONL file of {\"url\", \"timeout\", \"max_retries\"} objects; per-URL values override the flags (\"-\" for stdin)")
+	certWarnDaysFlag := flag.Int("cert-expiry-warn-days", 30, "With -capture-tls, flag certificate chains expiring within this many days")
+	hostHeaderFlag := flag.String("host-header", "", "Send this Host header (and TLS SNI) regardless of the URL's host, e.g. to hit an origin IP as a given vhost")
+	retryBackoffFlag := flag.Int("retry-backoff-ms", 0, "Base delay in milliseconds before the first retry, doubled on each further retry (0 = retry immediately)")
+	retryBackoffMaxFlag := flag.Int("retry-backoff-max-ms", 10000, "Upper bound in milliseconds for a single retry delay")
+	jitterFactorFlag := flag.Float64("jitter-factor", 1.0, "Fraction of each retry delay that is randomized: 0 = deterministic, 0.5 = equal jitter, 1 = full jitter")
+	maxURLBytesFlag := flag.Int64("max-url-bytes", 0, "Stop reading a response body after this many bytes, closing the connection and marking the result truncated (0 = no limit)")
+	streamLinesFlag := flag.Bool("stream-lines", false, "Stream response bodies line by line as NDJSON records instead of buffering them (for NDJSON/line-delimited endpoints)")
+	streamMaxLineFlag := flag.Int("stream-max-line-bytes", 1<<20, "Longest line accepted in -stream-lines mode")
+	fingerprintFlag := flag.Bool("fingerprint", false, "Hash each body into content_sha256 and print a run_fingerprint over the sorted (url, status, content_sha256) tuples")
+	concurrencyFlag := flag.Int("concurrency", 0, "Maximum URLs scraped at once (0 = GOMAXPROCS * -concurrency-per-cpu)")
+	concurrencyPerCPUFlag := flag.Int("concurrency-per-cpu", 32, "Workers per available CPU when -concurrency is not set")
+	autoMaxProcsFlag := flag.Bool("auto-maxprocs", false, "Lower GOMAXPROCS to the container's cgroup CPU quota before sizing the worker pool")
+	retryTruncatedFlag := flag.Bool("retry-on-truncated-body", false, "Treat a body shorter than its Content-Length as a failed attempt and retry it")
+	successStatusesFlag := flag.String("success-statuses", "200-299", "Status codes and ranges counted as success, e.g. 200-299,404")
+	hostSuccessStatusesFlag := flag.String("host-success-statuses", "", "Per-host success statuses overriding -success-statuses, e.g. \"api.example.com=200-299,404;*.cdn.net=200\"")
+	captureCookiesFlag := flag.Bool("capture-cookies", false, "Record every Set-Cookie line verbatim in set_cookies, including those on redirect responses")
+	parseCookiesFlag := flag.Bool("parse-cookies", false, "With -capture-cookies, also parse them into structured cookies (name, value, domain, expiry...)")
+	base64BinaryFlag := flag.Bool("base64-binary", false, "Base64-encode bodies that are not valid UTF-8 instead of letting JSON encoding replace the invalid bytes")
+	maxPerProxyFlag := flag.Int("max-attempts-per-proxy-per-url", 0, "Use any single proxy at most this many times within one URL's retries, forcing a switch to another proxy (0 = unlimited)")
+	emitAttemptsFlag := flag.Bool("emit-attempts", false, "Add an attempts array to the output with one record per request attempt (status, error, proxy, timing)")
+	dialRetriesFlag := flag.Int("dial-retries", 0, "Re-dial a failed TCP connection this many times within one attempt before the attempt counts as failed")
+	quietFlag := flag.Bool("quiet", false, "Suppress all stderr diagnostics except fatal errors")
+	proxyTypeTimeoutsFlag := flag.String("proxy-type-timeouts", "", "Per-proxy-type request timeouts in seconds, e.g. \"residential=30;datacenter=5\"; a per-URL or per-proxy timeout still wins")
+	redirectAllowedHostsFlag := flag.String("redirect-allowed-hosts", "", "Comma-separated hosts (and *.domain wildcards) redirects may lead to; others stop with an error")
+	redirectSameHostFlag := flag.Bool("redirect-same-host", false, "Only allow redirects that stay on the original URL's host (plus -redirect-allowed-hosts)")
+	sourceQueueFlag := flag.String("source-queue", "", "Run as a queue worker consuming URLs from a Redis list, e.g. redis://host:6379/urls (needs -tags queue)")
+	resultQueueFlag := flag.String("result-queue", "", "Redis list that -source-queue results are pushed to (default \"<list>:results\")")
+	compareProxyTypesFlag := flag.Bool("compare-proxy-types", false, "Scrape each URL through every proxy type (from #type= annotations) and report per-type success and latency")
+	compareSampleFlag := flag.Int("compare-sample", 0, "With -compare-proxy-types, compare only this many randomly chosen URLs (0 = all)")
+	baselineDirFlag := flag.String("baseline-dir", "", "Compare each body with the copy saved in this directory and report new/changed/unchanged")
+	updateBaselineFlag := flag.Bool("update-baseline", false, "With -baseline-dir, save the fetched bodies as the new baseline")
+	baselineDiffFlag := flag.Bool("baseline-diff", false, "With -baseline-dir, include a unified diff for changed bodies")
+	outputFileFlag := flag.String("output-file", "", "Write the JSON response to this file instead of stdout")
+	prettyFlag := flag.Bool("pretty", false, "Indent the JSON response")
+	forceJSONFlag := flag.Bool("force-json", false, "Write JSON even when stdout is a terminal, where a summary is shown by default")
+	dialTimeoutFlag := flag.Int("dial-timeout-ms", 0, "Connect timeout for the first attempt, doubled on each retry (0 = 30s default for every attempt)")
+	dialTimeoutMaxFlag := flag.Int("dial-timeout-max-ms", 30000, "Cap on the escalating -dial-timeout-ms")
+	serveMaxConcurrentFlag := flag.Int("serve-max-concurrent", 0, "In -serve mode, run at most this many scrape requests at once (0 = unlimited)")
+	serveQueueSizeFlag := flag.Int("serve-queue-size", 16, "In -serve mode, how many requests may wait for a slot before new ones get 503")
+	mirrorToFlag := flag.String("mirror-to", "", "Also fetch each URL from this template, e.g. \"https://staging.example.com{path}{query}\", and report divergences")
+	mirrorDiffFlag := flag.Bool("mirror-diff", false, "With -mirror-to, include a unified diff for diverging bodies")
+	maxDecompressedFlag := flag.Int64("max-decompressed-bytes", 100<<20, "Abort a transparently decompressed body once it expands past this many bytes (0 = unlimited)")
+	junitOutFlag := flag.String("junit-out", "", "Also write a JUnit XML report to this file, one test case per URL")
+	charsetOverridesFlag := flag.String("charset-overrides", "", "Per-host source charsets for transcoding text bodies to UTF-8, e.g. \"legacy.example.com=windows-1252;*.old.net=iso-8859-1\"")
+	transcodeFlag := flag.Bool("transcode", false, "Transcode text bodies (text/*, *+xml, JSON) to UTF-8 using the Content-Type charset, else a sniffed one; -charset-overrides still wins")
+	maxGoroutinesFlag := flag.Int("max-goroutines", 0, "Cap on background goroutines (workers, streams, mirror fetches); work beyond it runs with less parallelism (0 = unlimited)")
+	sortByFlag := flag.String("sort-by", "", "Sort results by status_code, elapsed_seconds, url or success before output")
+	sortOrderFlag := flag.String("sort-order", "asc", "Order for -sort-by: asc or desc")
+	retryOnHostChangeFlag := flag.Bool("retry-on-host-change", false, "Retry, through another proxy, when redirects leave the original registrable domain (e.g. example.com or example.co.uk)")
+	usageReportFlag := flag.String("usage-report", "", "Write per-proxy usage (requests, bytes, success rate, time) to this file, CSV if it ends in .csv, else JSON")
+	serveFlag := flag.String("serve", "", "Run as an HTTP server on this address (e.g. :8080) instead of scraping -urls once")
 
 	flag.Parse()
 
-	// Split URLs
-	urls := strings.Split(*urlsFlag, ",")
-	if len(urls) == 0 || (len(urls) == 1 && urls[0] == "") {
-		fmt.Fprintf(os.Stderr, "Error: No URLs provided\n")
+	if *quietFlag {
+		logOutput = io.Discard
+	}
+
+	if *renderFlag && *browserWSFlag == "" {
+		fmt.Fprintf(os.Stderr, "Error: -render requires -browser-ws\n")
+		os.Exit(1)
+	}
+	if *jitterFactorFlag < 0 || *jitterFactorFlag > 1 {
+		fmt.Fprintf(os.Stderr, "Error: -jitter-factor must be between 0.0 and 1.0\n")
+		os.Exit(1)
+	}
+	if *dialRetriesFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -dial-retries cannot be negative\n")
+		os.Exit(1)
+	}
+	if _, ok := resultLess[*sortByFlag]; *sortByFlag != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Error: -sort-by must be one of status_code, elapsed_seconds, url, success\n")
+		os.Exit(1)
+	}
+	if *sortOrderFlag != "asc" && *sortOrderFlag != "desc" {
+		fmt.Fprintf(os.Stderr, "Error: -sort-order must be asc or desc\n")
+		os.Exit(1)
+	}
+	charsets, err := newCharsetPolicy(*charsetOverridesFlag, *transcodeFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -charset-overrides: %v\n", err)
+		os.Exit(1)
+	}
+	if *serveMaxConcurrentFlag < 0 || *serveQueueSizeFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -serve-max-concurrent and -serve-queue-size cannot be negative\n")
+		os.Exit(1)
+	}
+	successRules, err := newSuccessPolicy(*successStatusesFlag, *hostSuccessStatusesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Clean up URLs
-	var cleanUrls []string
-	for _, u := range urls {
-		u = strings.TrimSpace(u)
-		if u != "" {
-			cleanUrls = append(cleanUrls, u)
-		}
+	proxyTypeTimeouts, err := parseProxyTypeTimeouts(*proxyTypeTimeoutsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -proxy-type-timeouts: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Split proxies
@@ -79,6 +262,10 @@ func main() {
 		for _, p := range strings.Split(*proxiesFlag, ",") {
 			p = strings.TrimSpace(p)
 			if p != "" {
+				if _, _, err := parseProxy(p); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: invalid proxy %s: %v\n", maskProxy(p), err)
+					os.Exit(1)
+				}
 				proxies = append(proxies, p)
 			}
 		}
@@ -87,11 +274,180 @@ func main() {
 	// Performance optimization: Seed the random number generator
 	rand.Seed(time.Now().UnixNano())
 
+	setGoroutineCap(*maxGoroutinesFlag)
+
+	// Size the worker pool from the CPUs actually available to us
+	if *autoMaxProcsFlag {
+		if procs := applyCgroupCPULimit(); procs > 0 {
+			logf("GOMAXPROCS set to %d from cgroup CPU quota", procs)
+		}
+	}
+
+	cfg := scrapeConfig{
+		ProxyType:         *proxyTypeFlag,
+		Timeout:           *timeoutFlag,
+		MaxRetries:        *maxRetriesFlag,
+		ProbeHeadFirst:    *probeHeadFirstFlag,
+		ProbeMaxBytes:     *probeMaxBytesFlag,
+		Render:            *renderFlag,
+		BrowserWS:         *browserWSFlag,
+		CaptureTLS:        *captureTLSFlag,
+		HostHeader:        *hostHeaderFlag,
+		BackoffBase:       time.Duration(*retryBackoffFlag) * time.Millisecond,
+		BackoffMax:        time.Duration(*retryBackoffMaxFlag) * time.Millisecond,
+		JitterFactor:      *jitterFactorFlag,
+		MaxURLBytes:       *maxURLBytesFlag,
+		CertWarnDays:      *certWarnDaysFlag,
+		HashContent:       *fingerprintFlag,
+		Concurrency:       resolveConcurrency(*concurrencyFlag, *concurrencyPerCPUFlag),
+		RetryTruncated:    *retryTruncatedFlag,
+		Success:           successRules,
+		CaptureCookies:    *captureCookiesFlag || *parseCookiesFlag,
+		ParseCookies:      *parseCookiesFlag,
+		Base64Binary:      *base64BinaryFlag,
+		MaxPerProxy:       *maxPerProxyFlag,
+		DialRetries:       *dialRetriesFlag,
+		DialTimeout:       time.Duration(*dialTimeoutFlag) * time.Millisecond,
+		DialTimeoutMax:    time.Duration(*dialTimeoutMaxFlag) * time.Millisecond,
+		MaxDecompressed:   *maxDecompressedFlag,
+		RetryOnHostChange: *retryOnHostChangeFlag,
+		Charsets:          charsets,
+		ProxyTypeTimeouts: proxyTypeTimeouts,
+		Redirects:         newRedirectPolicy(*redirectAllowedHostsFlag, *redirectSameHostFlag),
+	}
+
+	// Server mode takes URLs per request; the flags above become defaults
+	if *serveFlag != "" {
+		if err := runServer(*serveFlag, proxies, cfg, newAdmission(*serveMaxConcurrentFlag, *serveQueueSizeFlag)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running server: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Queue worker mode pulls URLs until stopped
+	if *sourceQueueFlag != "" {
+		if err := runQueueWorker(*sourceQueueFlag, *resultQueueFlag, proxies, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running queue worker: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Split URLs
+	var targets []scrapeTarget
+	for _, u := range strings.Split(*urlsFlag, ",") {
+		u = strings.TrimSpace(u)
+		if u != "" {
+			targets = append(targets, scrapeTarget{URL: u})
+		}
+	}
+
+	// Load JSONL input with per-URL overrides
+	if *inputFileFlag != "" {
+		fileTargets, err := loadTargets(*inputFileFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input file: %v\n", err)
+			os.Exit(1)
+		}
+		targets = append(targets, fileTargets...)
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No URLs provided\n")
+		os.Exit(1)
+	}
+
+	// Stream line-delimited bodies instead of collecting them
+	if *streamLinesFlag {
+		streamed, err := runStreamLines(targets, proxies, cfg, *streamMaxLineFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing stream output: %v\n", err)
+			os.Exit(1)
+		}
+		if *usageReportFlag != "" {
+			if err := writeUsageReport(*usageReportFlag, streamed); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing usage report: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
 	// Scrape URLs concurrently
 	startTime := time.Now()
-	results := scrapeURLs(cleanUrls, proxies, *proxyTypeFlag, *timeoutFlag, *maxRetriesFlag)
-	elapsedTime := time.Since(startTime).Seconds()
+	var response Response
+	var results []Result
+	var mirror *mirrorRun
+	if *compareProxyTypesFlag {
+		var comparison []ProxyTypeStats
+		var err error
+		results, comparison, err = compareProxyTypes(context.Background(), targets, proxies, cfg, *compareSampleFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		response = buildResponse(results, time.Since(startTime).Seconds(), "compare")
+		response.ProxyComparison = comparison
+	} else {
+		if *mirrorToFlag != "" {
+			mirror = startMirror(context.Background(), *mirrorToFlag, targets, proxies, cfg)
+		}
+		results = scrapeURLs(context.Background(), targets, proxies, cfg, nil)
+		response = buildResponse(results, time.Since(startTime).Seconds(), cfg.ProxyType)
+		if mirror != nil {
+			response.Mirror = mirror.compare(results, *mirrorDiffFlag)
+			logf("Mirror: %d of %d URLs diverged", response.Mirror.Diverged, response.Mirror.Compared)
+		}
+	}
+	if *baselineDirFlag != "" {
+		summary, err := compareBaseline(results, *baselineDirFlag, *updateBaselineFlag, *baselineDiffFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing with baseline: %v\n", err)
+			os.Exit(1)
+		}
+		response.Baseline = summary
+		logf("Baseline: %d changed, %d unchanged, %d new, %d skipped", summary.Changed, summary.Unchanged, summary.New, summary.Skipped)
+	}
+	if *emitAttemptsFlag {
+		for _, result := range results {
+			response.Attempts = append(response.Attempts, result.Attempts...)
+		}
+	}
+	if *fingerprintFlag {
+		response.RunFingerprint = runFingerprint(results)
+		logf("Run fingerprint: %s", response.RunFingerprint)
+	}
 
+	if *sortByFlag != "" {
+		sortResults(response.Results, *sortByFlag, *sortOrderFlag == "desc")
+	}
+	if *usageReportFlag != "" {
+		usageResults := results
+		if mirror != nil {
+			usageResults = append(append([]Result(nil), results...), mirror.results...) // shadow traffic is billed too
+		}
+		if err := writeUsageReport(*usageReportFlag, usageResults); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing usage report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *junitOutFlag != "" {
+		if err := writeJUnitReport(*junitOutFlag, response); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JUnit report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write response as JSON to stdout or -output-file
+	if err := writeOutput(response, outputOptions{File: *outputFileFlag, Pretty: *prettyFlag, ForceJSON: *forceJSONFlag}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing response: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// buildResponse wraps the collected results with their summary counts
+func buildResponse(results []Result, elapsedTime float64, proxyType string) Response {
 	// Count successful and failed results
 	successful := 0
 	for _, result := range results {
@@ -101,116 +457,241 @@ func main() {
 	}
 	failed := len(results) - successful
 
-	// Prepare response
-	response := Response{
-		Results:          results,
-		Total:            len(results),
-		Successful:       successful,
-		Failed:           failed,
-		TotalTimeSeconds: elapsedTime,
-		ProxyTypeUsed:    *proxyTypeFlag,
-	}
-
-	// Write response as JSON to stdout
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetEscapeHTML(false)
-	if err := encoder.Encode(response); err != nil {
-		fmt.Fprintf(os.Stderr, "Error encoding response to JSON: %v\n", err)
-		os.Exit(1)
+	return Response{
+		Results:            results,
+		Total:              len(results),
+		Successful:         successful,
+		Failed:             failed,
+		TotalTimeSeconds:   elapsedTime,
+		ProxyTypeUsed:      proxyType,
+		CertExpiryWarnings: certExpiryWarnings(results),
+		RetryEffectiveness: retryEffectiveness(results),
 	}
 }
 
-func scrapeURLs(urls []string, proxies []string, proxyType string, timeout int, maxRetries int) []Result {
+// retryEffectiveness aggregates AttemptsMade into first-try vs retry outcomes
+func retryEffectiveness(results []Result) RetryEffectiveness {
+	var eff RetryEffectiveness
+	for _, result := range results {
+		retries := result.AttemptsMade - 1
+		if retries < 0 {
+			retries = 0
+		}
+		eff.RetriesMade += retries
+
+		switch {
+		case result.Success && retries == 0:
+			eff.FirstAttemptSuccesses++
+		case result.Success:
+			eff.RetrySuccesses++
+			eff.UsefulRetries += retries
+		default:
+			eff.WastedRetries += retries
+		}
+	}
+	return eff
+}
+
+// scrapeURLs scrapes every target on a pool of cfg.Concurrency workers. When
+// onResult is non-nil it is called with each result as soon as it completes,
+// from the calling goroutine. Cancelling ctx aborts in-flight requests and
+// skips remaining retries.
+func scrapeURLs(ctx context.Context, targets []scrapeTarget, proxies []string, cfg scrapeConfig, onResult func(Result)) []Result {
 	// Create a wait group to track goroutines
 	var wg sync.WaitGroup
 
 	// Create a channel to collect results
-	resultsChan := make(chan Result, len(urls))
+	resultsChan := make(chan Result, len(targets))
 
-	// Process each URL concurrently
-	for _, url := range urls {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
+	// Feed targets to the workers
+	targetsChan := make(chan scrapeTarget, len(targets))
+	for _, target := range targets {
+		targetsChan <- target
+	}
+	close(targetsChan)
 
-			// Scrape the URL with retries
-			result := scrapeURL(url, proxies, proxyType, timeout, maxRetries)
-			resultsChan <- result
-		}(url)
+	workers := cfg.Concurrency
+	if workers < 1 || workers > len(targets) {
+		workers = len(targets)
 	}
 
-	// Wait for all goroutines to complete
-	wg.Wait()
-	close(resultsChan)
+	worker := func() {
+		defer wg.Done()
 
-	// Collect results from channel
+		for target := range targetsChan {
+			// Scrape the URL with retries, honouring per-URL overrides
+			resultsChan <- scrapeURL(ctx, target.URL, proxies, target.apply(cfg))
+		}
+	}
+
+	// Process URLs concurrently, with as many workers as -max-goroutines allows
+	started := 0
+	for ; started < workers; started++ {
+		wg.Add(1)
+		if !tryGo(worker) {
+			wg.Done()
+			break
+		}
+	}
+	if started < workers {
+		logf("goroutine cap reached, running %d of %d workers", started, workers)
+	}
+	if started == 0 {
+		// resultsChan holds every result, so the work can run inline
+		wg.Add(1)
+		worker()
+	}
+
+	// Close the channel once all goroutines complete
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	// Collect results from channel as they arrive
 	var results []Result
 	for result := range resultsChan {
+		if onResult != nil {
+			onResult(result)
+		}
 		results = append(results, result)
 	}
 
 	return results
 }
 
-func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int, maxRetries int) Result {
+func scrapeURL(ctx context.Context, targetURL string, proxies []string, cfg scrapeConfig) (result Result) {
+	// The browser does its own networking, so proxies do not apply in render mode
+	if cfg.Render {
+		return renderURL(ctx, targetURL, cfg)
+	}
+
 	startTime := time.Now()
+	proxyType := cfg.ProxyType
+	maxRetries := cfg.MaxRetries
 	var detailedErrorBuilder strings.Builder
 	var selectedProxy string
+	selector := newProxySelector(proxies, cfg.MaxPerProxy)
 	attemptsMade := 0
 
+	// Attach the per-attempt history and the last request sent to whichever
+	// Result is returned
+	var attempts []AttemptRecord
+	var effective *EffectiveRequest
+	defer func() {
+		result.Attempts = attempts
+		result.EffectiveRequest = effective
+	}()
+
 	for attempt := 0; attempt < maxRetries; attempt++ {
+		// Back off before retrying
+		if delay := backoffDelay(attempt, cfg.BackoffBase, cfg.BackoffMax, cfg.JitterFactor); delay > 0 {
+			fmt.Fprintf(&detailedErrorBuilder, "Backing off %s before retry\n", delay)
+			sleepContext(ctx, delay)
+		}
+
+		// Stop retrying once the caller has gone away
+		if ctx.Err() != nil {
+			fmt.Fprintf(&detailedErrorBuilder, "Scrape cancelled: %v\n", ctx.Err())
+			return Result{
+				URL:           targetURL,
+				Error:         fmt.Sprintf("Scrape cancelled: %v", ctx.Err()),
+				DetailedError: detailedErrorBuilder.String(),
+				ElapsedTime:   time.Since(startTime).Seconds(),
+				Success:       false,
+				ProxyUsed:     proxyType,
+				AttemptsMade:  attemptsMade,
+			}
+		}
+
 		attemptsMade++
 		attemptStartTime := time.Now()
+		record := func(method string, statusCode int, bytesRead int64, errMsg string, success bool) {
+			attempts = append(attempts, AttemptRecord{
+				URL:            targetURL,
+				Attempt:        attemptsMade,
+				Method:         method,
+				StartedAt:      attemptStartTime,
+				ElapsedSeconds: time.Since(attemptStartTime).Seconds(),
+				Proxy:          proxyLabel(selectedProxy),
+				proxyKey:       bareProxy(selectedProxy),
+				StatusCode:     statusCode,
+				BytesRead:      bytesRead,
+				Error:          errMsg,
+				Success:        success,
+			})
+		}
+		recordAttempt := func(statusCode int, bytesRead int64, errMsg string, success bool) {
+			record("GET", statusCode, bytesRead, errMsg, success)
+		}
 
 		// Record attempt information
 		fmt.Fprintf(&detailedErrorBuilder, "--- Attempt %d/%d at %s ---\n", attempt+1, maxRetries, time.Now().Format(time.RFC3339))
 
-		// Create a custom HTTP client
-		client := &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true, // Disable SSL verification for performance
-				},
-				MaxIdleConns:          100,
-				MaxIdleConnsPerHost:   10,
-				MaxConnsPerHost:       10,
-				IdleConnTimeout:       5 * time.Second,
-				TLSHandshakeTimeout:   5 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-				DisableKeepAlives:     false,
-			},
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				// Record redirect information
-				if len(via) >= 10 {
-					return fmt.Errorf("stopped after 10 redirects")
-				}
-				fmt.Fprintf(&detailedErrorBuilder, "Redirect to: %s\n", req.URL.String())
-				return nil
-			},
-		}
-
 		// Apply proxy if available
+		var proxyURL *url.URL
+		var proxyOpts proxyOptions
 		if len(proxies) > 0 {
 			// Select a random proxy
-			selectedProxy = proxies[rand.Intn(len(proxies))]
-			fmt.Fprintf(&detailedErrorBuilder, "Using proxy: %s\n", strings.Replace(selectedProxy, ":", "***:", 1)) // Hide password in logs
+			var switched bool
+			selectedProxy, switched = selector.next()
+			if switched {
+				fmt.Fprintf(&detailedErrorBuilder, "Proxy attempt limit of %d reached, switching proxy\n", cfg.MaxPerProxy)
+				logf("%s: proxy attempt limit of %d reached, switched to %s", targetURL, cfg.MaxPerProxy, maskProxy(selectedProxy))
+			}
+			fmt.Fprintf(&detailedErrorBuilder, "Using proxy: %s\n", maskProxy(selectedProxy)) // Hide password in logs
 
-			// Set up proxy URL
-			proxyURL, err := url.Parse(selectedProxy)
+			// Set up proxy URL, minus any #type=...&timeout=... annotations
+			var err error
+			proxyURL, proxyOpts, err = parseProxy(selectedProxy)
 			if err != nil {
 				fmt.Fprintf(&detailedErrorBuilder, "Error parsing proxy URL: %v\n", err)
+				recordAttempt(0, 0, fmt.Sprintf("Error parsing proxy URL: %v", err), false)
 				continue
 			}
-			client.Transport.(*http.Transport).Proxy = http.ProxyURL(proxyURL)
 		} else {
 			fmt.Fprintf(&detailedErrorBuilder, "No proxy used\n")
 		}
 
+		// Set-Cookie lines seen on redirect hops and the final response
+		var setCookies []string
+
+		timeout := cfg.timeoutFor(proxyOpts)
+		if timeout != cfg.Timeout {
+			fmt.Fprintf(&detailedErrorBuilder, "Using proxy timeout: %ds\n", timeout)
+		}
+
+		// Create an HTTP client on the shared transport for this proxy
+		client := &http.Client{
+			Timeout:   time.Duration(timeout) * time.Second,
+			Transport: clientTransport(proxyURL, targetURL, cfg.HostHeader),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				// Record redirect information
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				if !cfg.Redirects.allows(targetURL, req.URL) {
+					fmt.Fprintf(&detailedErrorBuilder, "Blocked redirect to disallowed host: %s\n", req.URL.String())
+					return &disallowedRedirectError{target: req.URL.String()}
+				}
+				fmt.Fprintf(&detailedErrorBuilder, "Redirect to: %s\n", req.URL.String())
+				if cfg.CaptureCookies && req.Response != nil {
+					setCookies = append(setCookies, req.Response.Header.Values("Set-Cookie")...)
+				}
+				return nil
+			},
+		}
+
 		// Create request
-		req, err := http.NewRequest("GET", targetURL, nil)
+		dialTimeout := dialTimeoutFor(attempt, cfg.DialTimeout, cfg.DialTimeoutMax)
+		if dialTimeout > 0 {
+			fmt.Fprintf(&detailedErrorBuilder, "Dial timeout: %s\n", dialTimeout)
+		}
+		attemptCtx, dial := withDialSettings(ctx, cfg.DialRetries, dialTimeout)
+		req, err := http.NewRequestWithContext(attemptCtx, "GET", targetURL, nil)
 		if err != nil {
 			fmt.Fprintf(&detailedErrorBuilder, "Error creating request: %v\n", err)
+			recordAttempt(0, 0, fmt.Sprintf("Error creating request: %v", err), false)
 			continue
 		}
 
@@ -219,6 +700,45 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 		req.Header.Set("User-Agent", userAgent)
 		fmt.Fprintf(&detailedErrorBuilder, "Using User-Agent: %s\n", userAgent)
 
+		// Override the Host header; Go ignores Header.Set("Host") so req.Host must be used
+		if cfg.HostHeader != "" {
+			req.Host = cfg.HostHeader
+			fmt.Fprintf(&detailedErrorBuilder, "Using Host header: %s\n", cfg.HostHeader)
+		}
+
+		// Probe with HEAD before committing to the full download
+		if cfg.ProbeHeadFirst {
+			headResp, skipReason := probeHead(client, req, targetURL, cfg, &detailedErrorBuilder)
+			if skipReason != "" {
+				fmt.Fprintf(&detailedErrorBuilder, "GET skipped: %s\n", skipReason)
+				record("HEAD", headResp.StatusCode, 0, skipReason, false)
+				return Result{
+					URL:             targetURL,
+					StatusCode:      headResp.StatusCode,
+					StatusText:      statusText(headResp),
+					FinalURL:        headResp.Request.URL.String(),
+					ResponseHeaders: flattenHeaders(headResp.Header),
+					Error:           skipReason,
+					DetailedError:   detailedErrorBuilder.String(),
+					ElapsedTime:     time.Since(startTime).Seconds(),
+					Success:         false,
+					ProxyUsed:       proxyType,
+					AttemptsMade:    attemptsMade,
+					HostHeader:      cfg.HostHeader,
+				}
+			}
+
+			// The probe went through the proxy too, so it is billed like any
+			// other request; time the GET on its own from here
+			if headResp != nil {
+				record("HEAD", headResp.StatusCode, 0, "", cfg.isSuccess(targetURL, headResp.StatusCode))
+			} else {
+				record("HEAD", 0, 0, "HEAD probe request failed", false)
+			}
+			attemptStartTime = time.Now()
+			setCookies = nil // the GET follows the same redirects and sets them again
+		}
+
 		// Log request details
 		fmt.Fprintf(&detailedErrorBuilder, "Sending request to: %s\n", targetURL)
 		reqDump, err := httputil.DumpRequestOut(req, false)
@@ -228,14 +748,42 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 
 		// Perform request
 		resp, err := client.Do(req)
+		for _, note := range dial.drain() {
+			fmt.Fprintf(&detailedErrorBuilder, "%s\n", note)
+		}
+		if resp != nil {
+			effective = effectiveRequest(resp.Request, proxyURL) // last redirect hop
+		} else {
+			effective = effectiveRequest(req, proxyURL)
+		}
 
 		// Handle request errors
 		if err != nil {
 			fmt.Fprintf(&detailedErrorBuilder, "Request error: %v\n", err)
+			recordAttempt(0, 0, err.Error(), false)
 			fmt.Fprintf(&detailedErrorBuilder, "Attempt %d failed after %s\n\n", attempt+1, time.Since(attemptStartTime))
 
+			// A blocked redirect would only repeat on retry
+			var blocked *disallowedRedirectError
+			if errors.As(err, &blocked) {
+				return Result{
+					URL:             targetURL,
+					StatusCode:      resp.StatusCode,
+					StatusText:      statusText(resp),
+					FinalURL:        resp.Request.URL.String(),
+					Error:           "redirect to disallowed host",
+					DetailedError:   detailedErrorBuilder.String(),
+					ElapsedTime:     time.Since(startTime).Seconds(),
+					Success:         false,
+					ProxyUsed:       proxyType,
+					AttemptsMade:    attemptsMade,
+					HostHeader:      cfg.HostHeader,
+					BlockedRedirect: blocked.target,
+				}
+			}
+
 			// Try again if not the last attempt
-			if attempt < maxRetries-1 {
+			if attempt < maxRetries-1 && ctx.Err() == nil {
 				continue
 			}
 
@@ -248,6 +796,7 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 				Success:       false,
 				ProxyUsed:     proxyType,
 				AttemptsMade:  attemptsMade,
+				HostHeader:    cfg.HostHeader,
 			}
 		}
 
@@ -255,10 +804,37 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 		fmt.Fprintf(&detailedErrorBuilder, "Response received with status: %d\n", resp.StatusCode)
 		fmt.Fprintf(&detailedErrorBuilder, "Final URL after redirects: %s\n", resp.Request.URL.String())
 
+		// Landing on an unrelated host usually means an anti-bot interstitial;
+		// retry through another proxy, and fail the URL if it persists
+		var hostChangeErr string
+		if cfg.RetryOnHostChange {
+			if from, to := hostnameOf(targetURL), resp.Request.URL.Hostname(); !relatedHosts(from, to) {
+				hostChangeErr = fmt.Sprintf("final URL host changed from %s to %s", from, to)
+				fmt.Fprintf(&detailedErrorBuilder, "%s\n", hostChangeErr)
+				logf("%s: %s (attempt %d/%d)", targetURL, hostChangeErr, attempt+1, maxRetries)
+				if attempt < maxRetries-1 && ctx.Err() == nil {
+					resp.Body.Close()
+					recordAttempt(resp.StatusCode, 0, hostChangeErr, false)
+					selector.exclude(selectedProxy)
+					fmt.Fprintf(&detailedErrorBuilder, "Attempt %d failed after %s\n\n", attempt+1, time.Since(attemptStartTime))
+					continue
+				}
+			}
+		}
+
 		// Get response headers
-		respHeaders := make(map[string]string)
-		for k, v := range resp.Header {
-			respHeaders[k] = strings.Join(v, ", ")
+		respHeaders := flattenHeaders(resp.Header)
+
+		// Capture TLS session details
+		var tlsInfo *TLSInfo
+		if cfg.CaptureTLS {
+			tlsInfo = captureTLSInfo(resp, cfg.CertWarnDays)
+			if tlsInfo != nil {
+				fmt.Fprintf(&detailedErrorBuilder, "TLS: %s, %s, ALPN %q\n", tlsInfo.Version, tlsInfo.CipherSuite, tlsInfo.NegotiatedProtocol)
+			}
+			if tlsInfo != nil && tlsInfo.CertNotAfter != nil {
+				fmt.Fprintf(&detailedErrorBuilder, "Certificate chain expires %s (%d days)\n", tlsInfo.CertNotAfter.Format(time.RFC3339), tlsInfo.DaysUntilExpiry)
+			}
 		}
 
 		// Log headers
@@ -269,13 +845,62 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 
 		// Read response body
 		defer resp.Body.Close()
-		bodyBytes, err := io.ReadAll(resp.Body)
+		var body io.Reader = resp.Body
+		if resp.Uncompressed && cfg.MaxDecompressed > 0 {
+			body = &decompressionLimitReader{r: resp.Body, remaining: cfg.MaxDecompressed}
+		}
+		bodyBytes, truncated, err := readBody(body, cfg.MaxURLBytes)
+
+		// A decompression bomb would expand the same way on every retry
+		if errors.Is(err, errDecompressionLimit) {
+			resp.Body.Close()
+			fmt.Fprintf(&detailedErrorBuilder, "Decompressed body exceeded %d bytes, aborted\n", cfg.MaxDecompressed)
+			logf("%s: gzip body exceeded the decompression limit of %d bytes", targetURL, cfg.MaxDecompressed)
+			recordAttempt(resp.StatusCode, int64(len(bodyBytes)), "decompression limit exceeded", false)
+			return Result{
+				URL:                        targetURL,
+				StatusCode:                 resp.StatusCode,
+				StatusText:                 statusText(resp),
+				FinalURL:                   resp.Request.URL.String(),
+				ResponseHeaders:            respHeaders,
+				Error:                      "decompression limit exceeded",
+				DetailedError:              detailedErrorBuilder.String(),
+				ElapsedTime:                time.Since(startTime).Seconds(),
+				Success:                    false,
+				ProxyUsed:                  proxyType,
+				AttemptsMade:               attemptsMade,
+				TLS:                        tlsInfo,
+				HostHeader:                 cfg.HostHeader,
+				BytesRead:                  int64(len(bodyBytes)),
+				DecompressionLimitExceeded: true,
+			}
+		}
+		if truncated {
+			// Closing early drops the connection instead of draining the rest
+			resp.Body.Close()
+			fmt.Fprintf(&detailedErrorBuilder, "Body truncated at per-URL limit of %d bytes\n", cfg.MaxURLBytes)
+		}
+
+		// Compare against Content-Length to catch connections dropped mid-body
+		if cfg.RetryTruncated && !truncated && (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) {
+			if shortErr := checkContentLength(resp, len(bodyBytes)); shortErr != nil {
+				logf("%s: %v (attempt %d/%d)", targetURL, shortErr, attempt+1, maxRetries)
+				err = shortErr
+			}
+		}
 		if err != nil {
 			fmt.Fprintf(&detailedErrorBuilder, "Error reading response body: %v\n", err)
 			fmt.Fprintf(&detailedErrorBuilder, "Attempt %d failed after %s\n\n", attempt+1, time.Since(attemptStartTime))
 
+			errorMsg := fmt.Sprintf("Failed to read response body: %v", err)
+			var shortErr *bodyTruncatedError
+			if errors.As(err, &shortErr) {
+				errorMsg = shortErr.Error()
+			}
+			recordAttempt(resp.StatusCode, int64(len(bodyBytes)), errorMsg, false)
+
 			// Try again if not the last attempt
-			if attempt < maxRetries-1 {
+			if attempt < maxRetries-1 && ctx.Err() == nil {
 				continue
 			}
 
@@ -283,32 +908,89 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 			return Result{
 				URL:             targetURL,
 				StatusCode:      resp.StatusCode,
+				StatusText:      statusText(resp),
 				FinalURL:        resp.Request.URL.String(),
 				ResponseHeaders: respHeaders,
-				Error:           fmt.Sprintf("Failed to read response body: %v", err),
+				Error:           errorMsg,
 				DetailedError:   detailedErrorBuilder.String(),
 				ElapsedTime:     time.Since(startTime).Seconds(),
 				Success:         false,
 				ProxyUsed:       proxyType,
 				AttemptsMade:    attemptsMade,
+				TLS:             tlsInfo,
+				HostHeader:      cfg.HostHeader,
+				BytesRead:       int64(len(bodyBytes)),
 			}
 		}
 
 		fmt.Fprintf(&detailedErrorBuilder, "Successfully read response body (%d bytes)\n", len(bodyBytes))
+
+		var contentHash string
+		if cfg.HashContent {
+			contentHash = contentSHA256(bodyBytes)
+		}
+
+		// Transcode to UTF-8 when an override or -transcode applies
+		text := bodyBytes
+		charset, charsetSource := cfg.Charsets.detect(targetURL, resp.Header.Get("Content-Type"), bodyBytes)
+		if charset != "" {
+			if decoded, ok := transcode(bodyBytes, charset); ok {
+				fmt.Fprintf(&detailedErrorBuilder, "Decoded body as %s (%s)\n", charset, charsetSource)
+				text = decoded
+			} else {
+				fmt.Fprintf(&detailedErrorBuilder, "Cannot transcode unsupported charset %q (%s), body left as is\n", charset, charsetSource)
+				charset, charsetSource = "", ""
+			}
+		}
+
+		// JSON encoding silently replaces invalid UTF-8, so flag it and
+		// optionally switch to a lossless encoding
+		content, contentEncoding := string(text), ""
+		contentBinary := !utf8.Valid(text)
+		if contentBinary {
+			fmt.Fprintf(&detailedErrorBuilder, "Response body is not valid UTF-8\n")
+			if cfg.Base64Binary {
+				content, contentEncoding = base64.StdEncoding.EncodeToString(bodyBytes), "base64"
+			}
+		}
+
+		// Keep Set-Cookie lines separate; the joined header map mangles them
+		var cookies []Cookie
+		if cfg.CaptureCookies {
+			setCookies = append(setCookies, resp.Header.Values("Set-Cookie")...)
+			if cfg.ParseCookies {
+				cookies = parseSetCookies(setCookies)
+			}
+		}
 		fmt.Fprintf(&detailedErrorBuilder, "Attempt %d succeeded after %s\n", attempt+1, time.Since(attemptStartTime))
+		success := hostChangeErr == "" && cfg.isSuccess(targetURL, resp.StatusCode)
+		recordAttempt(resp.StatusCode, int64(len(bodyBytes)), hostChangeErr, success)
 
 		// Success case
 		return Result{
 			URL:             targetURL,
 			StatusCode:      resp.StatusCode,
+			StatusText:      statusText(resp),
 			FinalURL:        resp.Request.URL.String(),
 			ResponseHeaders: respHeaders,
-			Content:         string(bodyBytes),
+			Content:         content,
+			Error:           hostChangeErr,
 			DetailedError:   detailedErrorBuilder.String(), // Include detailed log even on success
 			ElapsedTime:     time.Since(startTime).Seconds(),
-			Success:         resp.StatusCode >= 200 && resp.StatusCode < 300,
+			Success:         success,
 			ProxyUsed:       proxyType,
 			AttemptsMade:    attemptsMade,
+			TLS:             tlsInfo,
+			HostHeader:      cfg.HostHeader,
+			BytesRead:       int64(len(bodyBytes)),
+			Truncated:       truncated,
+			ContentSHA256:   contentHash,
+			SetCookies:      setCookies,
+			Cookies:         cookies,
+			ContentBinary:   contentBinary,
+			ContentEncoding: contentEncoding,
+			Charset:         charset,
+			CharsetSource:   charsetSource,
 		}
 	}
 
@@ -322,4 +1004,124 @@ func scrapeURL(targetURL string, proxies []string, proxyType string, timeout int
 		ProxyUsed:     proxyType,
 		AttemptsMade:  attemptsMade,
 	}
-}
\ No newline at end of file
+}
+
+// probeHead issues a HEAD copy of the prepared GET request and returns a
+// non-empty reason when the GET is not worth making. Servers that reject HEAD
+// (405/501) and transport errors fall back to the GET so the probe never loses
+// a result. The status is judged by the run's success policy for targetURL.
+func probeHead(client *http.Client, getReq *http.Request, targetURL string, cfg scrapeConfig, log io.Writer) (*http.Response, string) {
+	probeStart := time.Now()
+	fmt.Fprintf(log, "HEAD probe: %s\n", getReq.URL.String())
+
+	req := getReq.Clone(getReq.Context())
+	req.Method = "HEAD"
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(log, "HEAD probe: request error after %s, falling back to GET: %v\n", time.Since(probeStart), err)
+		return nil, ""
+	}
+	resp.Body.Close()
+
+	fmt.Fprintf(log, "HEAD probe: status %d, Content-Length %d after %s\n", resp.StatusCode, resp.ContentLength, time.Since(probeStart))
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		fmt.Fprintf(log, "HEAD probe: server does not support HEAD, falling back to GET\n")
+		return resp, ""
+	case !cfg.isSuccess(targetURL, resp.StatusCode):
+		return resp, fmt.Sprintf("HEAD probe returned status %d", resp.StatusCode)
+	case cfg.ProbeMaxBytes > 0 && resp.ContentLength > cfg.ProbeMaxBytes:
+		return resp, fmt.Sprintf("HEAD probe reported Content-Length %d exceeding limit of %d bytes", resp.ContentLength, cfg.ProbeMaxBytes)
+	}
+
+	fmt.Fprintf(log, "HEAD probe: proceeding with GET\n")
+	return resp, ""
+}
+
+// readBody reads the whole body, or at most limit bytes when limit > 0. It
+// reads one byte past the limit so an exactly-sized body is not reported as
+// truncated.
+func readBody(body io.Reader, limit int64) ([]byte, bool, error) {
+	if limit <= 0 {
+		data, err := io.ReadAll(body)
+		return data, false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if int64(len(data)) > limit {
+		return data[:limit], true, err
+	}
+	return data, false, err
+}
+
+// errDecompressionLimit is returned once a decompressed body passes
+// -max-decompressed-bytes
+var errDecompressionLimit = errors.New("decompression limit exceeded")
+
+// decompressionLimitReader caps the output of the transport's transparent
+// gzip decoding, so a small compressed payload cannot expand without bound
+type decompressionLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *decompressionLimitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Probe for more data so a body of exactly the limit still succeeds
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, errDecompressionLimit
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// bodyTruncatedError reports a body shorter than its advertised Content-Length
+type bodyTruncatedError struct {
+	got, want int64
+}
+
+func (e *bodyTruncatedError) Error() string {
+	return fmt.Sprintf("body truncated: got %d of %d bytes", e.got, e.want)
+}
+
+// checkContentLength returns a bodyTruncatedError when fewer bytes were read
+// than the response advertised. Transparently decompressed responses are
+// skipped since their Content-Length describes the compressed stream.
+func checkContentLength(resp *http.Response, n int) error {
+	if resp.ContentLength < 0 || resp.Uncompressed || int64(n) >= resp.ContentLength {
+		return nil
+	}
+	return &bodyTruncatedError{got: int64(n), want: resp.ContentLength}
+}
+
+// hostWithoutPort strips an optional :port suffix from a Host header value
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// statusText returns the reason phrase of resp.Status, which may differ from
+// http.StatusText on non-standard servers and proxies
+func statusText(resp *http.Response) string {
+	return strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+}
+
+// flattenHeaders joins multi-value headers into a single comma-separated string
+func flattenHeaders(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for k, v := range header {
+		flat[k] = strings.Join(v, ", ")
+	}
+	return flat
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Shadow traffic (-mirror-to)
+//
+// Every target is also fetched from a URL derived from a template, e.g.
+//
+//	-mirror-to "https://staging.example.com{path}{query}"
+//
+// Placeholders: {url} (the whole primary URL), {scheme}, {host}, {path} and
+// {query} (including its "?", empty when there is none). Shadow fetches run
+// concurrently with the primary run and never change the primary Results;
+// the comparison is only reported in Response.Mirror.
+
+// MirrorDivergence is one URL whose shadow response differed
+type MirrorDivergence struct {
+	URL              string `json:"url"`
+	MirrorURL        string `json:"mirror_url"`
+	StatusCode       int    `json:"status_code"`
+	MirrorStatusCode int    `json:"mirror_status_code"`
+	StatusDiffers    bool   `json:"status_differs"`
+	BodyDiffers      bool   `json:"body_differs"`
+	MirrorError      string `json:"mirror_error,omitempty"`
+	Diff             string `json:"diff,omitempty"`
+}
+
+// MirrorSummary reports the shadow comparison for a run
+type MirrorSummary struct {
+	Template    string             `json:"template"`
+	Compared    int                `json:"compared"`
+	Matched     int                `json:"matched"`
+	Diverged    int                `json:"diverged"`
+	Divergences []MirrorDivergence `json:"divergences,omitempty"`
+}
+
+// mirrorURL fills the template for one primary URL
+func mirrorURL(template, targetURL string) (string, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return "", err
+	}
+	query := ""
+	if u.RawQuery != "" {
+		query = "?" + u.RawQuery
+	}
+	mirrored := strings.NewReplacer(
+		"{url}", targetURL,
+		"{scheme}", u.Scheme,
+		"{host}", u.Host,
+		"{path}", u.EscapedPath(),
+		"{query}", query,
+	).Replace(template)
+	if m, err := url.Parse(mirrored); err != nil || m.Host == "" {
+		return "", fmt.Errorf("template %q gives invalid URL %q", template, mirrored)
+	}
+	return mirrored, nil
+}
+
+// mirrorRun is an in-flight shadow fetch of a run's targets
+type mirrorRun struct {
+	template string
+	mirrors  map[string]string // primary URL -> mirror URL
+	errs     map[string]error  // primary URL -> template failure
+	done     chan []Result
+	results  []Result // shadow results, once compare has run
+}
+
+// startMirror fetches the shadow URLs in the background, with the same
+// settings as the primary run
+func startMirror(ctx context.Context, template string, targets []scrapeTarget, proxies []string, cfg scrapeConfig) *mirrorRun {
+	m := &mirrorRun{
+		template: template,
+		mirrors:  make(map[string]string),
+		errs:     make(map[string]error),
+		done:     make(chan []Result, 1),
+	}
+
+	var shadow []scrapeTarget
+	for _, target := range targets {
+		mirrored, err := mirrorURL(template, target.URL)
+		if err != nil {
+			m.errs[target.URL] = err
+			continue
+		}
+		m.mirrors[target.URL] = mirrored
+		target.URL = mirrored
+		shadow = append(shadow, target)
+	}
+	fetch := func() { m.done <- scrapeURLs(ctx, shadow, proxies, cfg, nil) }
+	if !tryGo(fetch) {
+		fetch() // no goroutine to spare, fetch before the primary run
+	}
+	return m
+}
+
+// compare waits for the shadow fetches and checks each against its primary
+// result, matching on status code and body
+func (m *mirrorRun) compare(results []Result, withDiff bool) *MirrorSummary {
+	m.results = <-m.done
+	shadows := make(map[string]Result)
+	for _, shadow := range m.results {
+		shadows[shadow.URL] = shadow
+	}
+
+	summary := &MirrorSummary{Template: m.template}
+	for _, primary := range results {
+		summary.Compared++
+		d := MirrorDivergence{URL: primary.URL, MirrorURL: m.mirrors[primary.URL], StatusCode: primary.StatusCode}
+		if err, ok := m.errs[primary.URL]; ok {
+			d.MirrorError = err.Error()
+		} else {
+			shadow := shadows[d.MirrorURL]
+			d.MirrorStatusCode = shadow.StatusCode
+			d.MirrorError = shadow.Error
+			d.StatusDiffers = shadow.StatusCode != primary.StatusCode
+			d.BodyDiffers = shadow.Content != primary.Content
+			if d.BodyDiffers && withDiff {
+				d.Diff = unifiedDiff(primary.Content, shadow.Content, primary.URL, d.MirrorURL)
+			}
+		}
+
+		if d.StatusDiffers || d.BodyDiffers || d.MirrorError != "" {
+			summary.Diverged++
+			summary.Divergences = append(summary.Divergences, d)
+		} else {
+			summary.Matched++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// outputOptions selects where and how the final Response is written
+type outputOptions struct {
+	File      string // -output-file, "" for stdout
+	Pretty    bool
+	ForceJSON bool
+}
+
+// stdoutIsTerminal reports whether stdout is an interactive terminal rather
+// than a pipe or file. A character-device check is enough here and avoids a
+// dependency on golang.org/x/term.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writeOutput writes response as JSON to the output file or stdout. A
+// terminal on stdout gets a compact summary instead of a JSON flood, unless
+// -pretty or -force-json asks for the JSON anyway.
+func writeOutput(response Response, opts outputOptions) error {
+	if opts.File != "" {
+		f, err := os.Create(opts.File)
+		if err != nil {
+			return err
+		}
+		if err := encodeResponse(f, response, opts.Pretty); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if stdoutIsTerminal() {
+			writeSummary(os.Stdout, response)
+			fmt.Fprintf(os.Stdout, "Full results written to %s\n", opts.File)
+		}
+		return nil
+	}
+
+	if stdoutIsTerminal() && !opts.Pretty && !opts.ForceJSON {
+		writeSummary(os.Stdout, response)
+		fmt.Fprintf(os.Stdout, "stdout is a terminal, so only a summary was shown. Use -output-file <path> or -pretty for the results, or -force-json for raw JSON.\n")
+		return nil
+	}
+	return encodeResponse(os.Stdout, response, opts.Pretty)
+}
+
+func encodeResponse(w io.Writer, response Response, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(response)
+}
+
+// writeSummary prints one line per URL plus the run totals
+func writeSummary(w io.Writer, response Response) {
+	fmt.Fprintf(w, "Scraped %d URLs in %.2fs: %d successful, %d failed\n",
+		response.Total, response.TotalTimeSeconds, response.Successful, response.Failed)
+	for _, result := range response.Results {
+		status := "ERR"
+		if result.StatusCode != 0 {
+			status = fmt.Sprintf("%d", result.StatusCode)
+		}
+		mark := "ok  "
+		if !result.Success {
+			mark = "FAIL"
+		}
+		line := fmt.Sprintf("  %s %s %7.2fs %8dB  %s", mark, status, result.ElapsedTime, result.BytesRead, result.URL)
+		if result.Error != "" {
+			line += "  " + strings.SplitN(result.Error, "\n", 2)[0]
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// resultLess orders results by a -sort-by key, ascending
+var resultLess = map[string]func(a, b Result) bool{
+	"status_code":     func(a, b Result) bool { return a.StatusCode < b.StatusCode },
+	"elapsed_seconds": func(a, b Result) bool { return a.ElapsedTime < b.ElapsedTime },
+	"url":             func(a, b Result) bool { return a.URL < b.URL },
+	"success":         func(a, b Result) bool { return !a.Success && b.Success },
+}
+
+// sortResults orders results in place by key, ties broken by URL so the
+// output is deterministic
+func sortResults(results []Result, key string, descending bool) {
+	less := resultLess[key]
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if descending {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return results[i].URL < results[j].URL
+	})
+}
@@ -0,0 +1,234 @@
+//go:build queue
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Queue worker mode (-source-queue), built only with -tags queue.
+//
+// The worker pops URLs from a Redis list and pushes each Result, JSON-encoded,
+// onto a result list until SIGINT/SIGTERM. A queue entry is either a bare URL
+// or a JSONL-style object with per-URL overrides, as accepted by -input-file:
+//
+//	https://example.com/
+//	{"url": "https://example.com/slow", "timeout": 30}
+//
+// URLs are pulled in batches of up to -concurrency and scraped through the
+// usual worker pool; a result is pushed as soon as its URL completes. A popped
+// entry whose scrape is interrupted by shutdown is lost, matching Redis list
+// (at-most-once) semantics.
+
+// queuePollTimeout bounds each blocking pop so shutdown is noticed promptly
+const queuePollTimeout = 5 * time.Second
+
+// runQueueWorker consumes source, e.g. redis://:password@host:6379/urls where
+// the path names the list. Results go to resultList, defaulting to
+// "<list>:results".
+func runQueueWorker(source, resultList string, proxies []string, cfg scrapeConfig) error {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return fmt.Errorf("invalid -source-queue %q, expected redis://[:password@]host:port/list", source)
+	}
+	list := strings.TrimPrefix(u.Path, "/")
+	if list == "" {
+		return fmt.Errorf("-source-queue %q names no list", source)
+	}
+	if resultList == "" {
+		resultList = list + ":results"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logf("Consuming %s from %s, results to %s", list, u.Redacted(), resultList)
+	for ctx.Err() == nil {
+		conn, err := dialRedis(ctx, u)
+		if err != nil {
+			logf("queue: %v, reconnecting", err)
+			sleepContext(ctx, time.Second)
+			continue
+		}
+		err = consumeQueue(ctx, conn, list, resultList, proxies, cfg)
+		conn.Close()
+		if err != nil && ctx.Err() == nil {
+			logf("queue: %v, reconnecting", err)
+			sleepContext(ctx, time.Second)
+		}
+	}
+	logf("Queue worker stopped")
+	return nil
+}
+
+// consumeQueue runs batches until ctx is cancelled or the connection fails
+func consumeQueue(ctx context.Context, conn *redisConn, list, resultList string, proxies []string, cfg scrapeConfig) error {
+	for ctx.Err() == nil {
+		batch, err := popBatch(conn, list, cfg.Concurrency)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		var pushErr error
+		scrapeURLs(ctx, batch, proxies, cfg, func(result Result) {
+			if pushErr != nil {
+				return
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				logf("queue: encoding result for %s: %v", result.URL, err)
+				return
+			}
+			_, pushErr = conn.do("RPUSH", resultList, string(data))
+		})
+		if pushErr != nil {
+			return pushErr
+		}
+	}
+	return nil
+}
+
+// popBatch blocks for one entry, then takes up to size-1 more without waiting
+func popBatch(conn *redisConn, list string, size int) ([]scrapeTarget, error) {
+	reply, err := conn.do("BLPOP", list, strconv.Itoa(int(queuePollTimeout/time.Second)))
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	pair, ok := reply.([]any)
+	if !ok || len(pair) != 2 {
+		return nil, fmt.Errorf("unexpected BLPOP reply %v", reply)
+	}
+	entries := []any{pair[1]}
+	for len(entries) < size {
+		reply, err := conn.do("LPOP", list)
+		if err != nil {
+			return nil, err
+		}
+		if reply == nil {
+			break
+		}
+		entries = append(entries, reply)
+	}
+
+	var batch []scrapeTarget
+	for _, entry := range entries {
+		s, _ := entry.(string)
+		target, err := parseQueueEntry(s)
+		if err != nil {
+			logf("queue: skipping entry %q: %v", s, err)
+			continue
+		}
+		batch = append(batch, target)
+	}
+	return batch, nil
+}
+
+func parseQueueEntry(entry string) (scrapeTarget, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.HasPrefix(entry, "{") {
+		if entry == "" {
+			return scrapeTarget{}, errors.New("empty entry")
+		}
+		return scrapeTarget{URL: entry}, nil
+	}
+	return parseTarget(entry)
+}
+
+// redisConn is a minimal RESP2 client, just enough for list commands
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dialRedis(ctx context.Context, u *url.URL) (*redisConn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: c, r: bufio.NewReader(c)}
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if name := u.User.Username(); name != "" {
+			args = []string{"AUTH", name, password}
+		}
+		if _, err := conn.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("AUTH: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// do sends one command and returns its reply: string, int64, []any, or nil
+// for a null reply. Redis error replies are returned as errors.
+func (c *redisConn) do(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty RESP reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown RESP reply %q", line)
+}
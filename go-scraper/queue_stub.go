@@ -0,0 +1,10 @@
+//go:build !queue
+
+package main
+
+import "errors"
+
+// runQueueWorker is unavailable without the queue adapter; see queue.go
+func runQueueWorker(source, resultList string, proxies []string, cfg scrapeConfig) error {
+	return errors.New("-source-queue requires a build with -tags queue")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// redirectPolicy limits where redirects may lead, for scoped crawls and to
+// keep a target from bouncing the scraper onto internal hosts. A nil policy
+// allows every redirect.
+type redirectPolicy struct {
+	allowed  map[string]bool // hosts and "*." wildcards from -redirect-allowed-hosts
+	sameHost bool            // the original URL's host is allowed too
+}
+
+// newRedirectPolicy parses -redirect-allowed-hosts and -redirect-same-host.
+// With only -redirect-same-host set, redirects must stay on the original host.
+func newRedirectPolicy(hostsSpec string, sameHost bool) *redirectPolicy {
+	allowed := make(map[string]bool)
+	for _, host := range strings.Split(hostsSpec, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			allowed[host] = true
+		}
+	}
+	if len(allowed) == 0 && !sameHost {
+		return nil
+	}
+	return &redirectPolicy{allowed: allowed, sameHost: sameHost}
+}
+
+// allows reports whether a redirect from the scrape of targetURL may go to next
+func (p *redirectPolicy) allows(targetURL string, next *url.URL) bool {
+	if p == nil {
+		return true
+	}
+	host := next.Hostname()
+	if p.sameHost {
+		if original, err := url.Parse(targetURL); err == nil && strings.EqualFold(original.Hostname(), host) {
+			return true
+		}
+	}
+	_, ok := matchHost(p.allowed, host)
+	return ok
+}
+
+// hostnameOf returns the lowercased hostname of rawURL, "" if it does not parse
+func hostnameOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// relatedHosts treats hosts under the same registrable domain (example.com,
+// www.example.com, shop.example.com and auth.example.com) as the same site
+// for -retry-on-host-change. IP addresses only match themselves.
+func relatedHosts(a, b string) bool {
+	a, b = strings.ToLower(strings.TrimSuffix(a, ".")), strings.ToLower(strings.TrimSuffix(b, "."))
+	if a == b {
+		return true
+	}
+	if net.ParseIP(a) != nil || net.ParseIP(b) != nil {
+		return false
+	}
+	return registrableDomain(a) == registrableDomain(b)
+}
+
+// secondLevelSuffixes are the labels that form a public suffix under a
+// country-code TLD, as in co.uk, com.au and ne.jp
+var secondLevelSuffixes = map[string]bool{
+	"co": true, "com": true, "net": true, "org": true, "ac": true,
+	"gov": true, "edu": true, "ne": true, "or": true,
+}
+
+// registrableDomain approximates the public-suffix-plus-one of host without
+// a suffix list: the last two labels, or three when the second-to-last is one
+// of secondLevelSuffixes under a two-letter TLD. Short brand names such as
+// zdf.de or abc.io keep two labels.
+func registrableDomain(host string) string {
+	labels := strings.Split(host, ".")
+	keep := 2
+	if n := len(labels); n >= 3 && len(labels[n-1]) == 2 && secondLevelSuffixes[labels[n-2]] {
+		keep = 3
+	}
+	if len(labels) <= keep {
+		return host
+	}
+	return strings.Join(labels[len(labels)-keep:], ".")
+}
+
+// disallowedRedirectError stops a redirect chain; it is not retried since
+// the target would redirect the same way again
+type disallowedRedirectError struct {
+	target string
+}
+
+func (e *disallowedRedirectError) Error() string {
+	return "redirect to disallowed host: " + e.target
+}
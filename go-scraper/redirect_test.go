@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestRelatedHosts(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", true},
+		{"shop.example.com", "auth.example.com", true},
+		{"www.zdf.de", "login.zdf.de", true},
+		{"www.sap.de", "accounts.sap.de", true},
+		{"app.abc.io", "auth.abc.io", true},
+		{"Shop.Example.COM.", "auth.example.com", true},
+		{"a.example.co.uk", "b.example.co.uk", true},
+		{"www.shop.com.au", "login.shop.com.au", true},
+		{"example.com", "example.org", false},
+		{"example.co.uk", "other.co.uk", false},
+		{"shop.example.com", "captcha.cdn.net", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1", "localhost", false},
+		{"10.0.0.1", "20.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := relatedHosts(tt.a, tt.b); got != tt.want {
+			t.Errorf("relatedHosts(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+// Render mode drives an external headless browser over the Chrome DevTools
+// Protocol instead of fetching the raw HTML with net/http, so JS-rendered
+// content ends up in Result.Content.
+//
+// External dependency: a Chrome/Chromium instance started with
+// --remote-debugging-port (e.g. `chromium --headless --remote-debugging-port=9222`)
+// or a hosted CDP browser service. None is bundled in the Docker image. Point
+// -browser-ws at either the browser WebSocket URL
+// (ws://host:9222/devtools/browser/<id>) or the HTTP debugging endpoint
+// (http://host:9222), which is resolved through /json/version.
+//
+// The WebSocket client below implements only what CDP needs (client-side text
+// frames, fragmentation, ping/pong, close) so the scraper stays dependency free.
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// renderURL scrapes a URL through the headless browser with the same retry
+// semantics and Result shape as scrapeURL
+func renderURL(ctx context.Context, targetURL string, cfg scrapeConfig) Result {
+	startTime := time.Now()
+	var detailedErrorBuilder strings.Builder
+	attemptsMade := 0
+	var lastErr error
+
+	for attempt := 0; attempt < cfg.MaxRetries && ctx.Err() == nil; attempt++ {
+		if delay := backoffDelay(attempt, cfg.BackoffBase, cfg.BackoffMax, cfg.JitterFactor); delay > 0 {
+			fmt.Fprintf(&detailedErrorBuilder, "Backing off %s before retry\n", delay)
+			if !sleepContext(ctx, delay) {
+				break
+			}
+		}
+
+		attemptsMade++
+		attemptStartTime := time.Now()
+
+		fmt.Fprintf(&detailedErrorBuilder, "--- Render attempt %d/%d at %s ---\n", attempt+1, cfg.MaxRetries, time.Now().Format(time.RFC3339))
+		fmt.Fprintf(&detailedErrorBuilder, "Rendering via browser: %s\n", cfg.BrowserWS)
+
+		attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout)*time.Second)
+		page, err := renderPage(attemptCtx, cfg.BrowserWS, targetURL, &detailedErrorBuilder)
+		cancel()
+
+		if err != nil {
+			lastErr = err
+			fmt.Fprintf(&detailedErrorBuilder, "Render error: %v\n", err)
+			fmt.Fprintf(&detailedErrorBuilder, "Attempt %d failed after %s\n\n", attempt+1, time.Since(attemptStartTime))
+			continue
+		}
+
+		fmt.Fprintf(&detailedErrorBuilder, "Rendered document status: %d\n", page.StatusCode)
+		fmt.Fprintf(&detailedErrorBuilder, "Successfully rendered page (%d bytes)\n", len(page.HTML))
+		fmt.Fprintf(&detailedErrorBuilder, "Attempt %d succeeded after %s\n", attempt+1, time.Since(attemptStartTime))
+
+		return Result{
+			URL:             targetURL,
+			StatusCode:      page.StatusCode,
+			StatusText:      page.StatusText,
+			FinalURL:        page.FinalURL,
+			ResponseHeaders: page.Headers,
+			Content:         page.HTML,
+			DetailedError:   detailedErrorBuilder.String(),
+			ElapsedTime:     time.Since(startTime).Seconds(),
+			Success:         cfg.isSuccess(targetURL, page.StatusCode),
+			ProxyUsed:       cfg.ProxyType,
+			AttemptsMade:    attemptsMade,
+		}
+	}
+
+	if ctx.Err() != nil {
+		lastErr = ctx.Err()
+	}
+	return Result{
+		URL:           targetURL,
+		Error:         fmt.Sprintf("All %d render attempts failed: %v", cfg.MaxRetries, lastErr),
+		DetailedError: detailedErrorBuilder.String(),
+		ElapsedTime:   time.Since(startTime).Seconds(),
+		Success:       false,
+		ProxyUsed:     cfg.ProxyType,
+		AttemptsMade:  attemptsMade,
+	}
+}
+
+// renderedPage is the outcome of loading one URL in the browser
+type renderedPage struct {
+	HTML       string
+	StatusCode int
+	StatusText string
+	FinalURL   string
+	Headers    map[string]string
+}
+
+// renderPage opens a fresh tab, navigates to targetURL, waits for the load
+// event and returns the serialized DOM
+func renderPage(ctx context.Context, browserEndpoint string, targetURL string, log io.Writer) (*renderedPage, error) {
+	wsURL, err := resolveBrowserWS(ctx, browserEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialWebSocket(ctx, wsURL)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to browser: %w", err)
+	}
+	defer conn.Close()
+	cdp := &cdpClient{ws: conn}
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := cdp.call("Target.createTarget", map[string]any{"url": "about:blank"}, "", &target); err != nil {
+		return nil, err
+	}
+	defer cdp.call("Target.closeTarget", map[string]any{"targetId": target.TargetID}, "", nil)
+
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := cdp.call("Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, "", &session); err != nil {
+		return nil, err
+	}
+	for _, method := range []string{"Page.enable", "Network.enable"} {
+		if err := cdp.call(method, nil, session.SessionID, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	page := &renderedPage{FinalURL: targetURL}
+	var frameID string
+	loaded := false
+	cdp.onEvent = func(method string, sessionID string, params json.RawMessage) {
+		if sessionID != session.SessionID {
+			return
+		}
+		switch method {
+		case "Network.responseReceived":
+			var ev struct {
+				Type     string `json:"type"`
+				FrameID  string `json:"frameId"`
+				Response struct {
+					URL        string            `json:"url"`
+					Status     int               `json:"status"`
+					StatusText string            `json:"statusText"`
+					Headers    map[string]string `json:"headers"`
+				} `json:"response"`
+			}
+			if json.Unmarshal(params, &ev) == nil && ev.Type == "Document" && (frameID == "" || ev.FrameID == frameID) {
+				page.StatusCode = ev.Response.Status
+				page.StatusText = ev.Response.StatusText
+				page.FinalURL = ev.Response.URL
+				page.Headers = ev.Response.Headers
+				fmt.Fprintf(log, "Document response: %d %s\n", ev.Response.Status, ev.Response.URL)
+			}
+		case "Page.loadEventFired":
+			loaded = true
+		}
+	}
+
+	var nav struct {
+		FrameID   string `json:"frameId"`
+		ErrorText string `json:"errorText"`
+	}
+	fmt.Fprintf(log, "Navigating to: %s\n", targetURL)
+	if err := cdp.call("Page.navigate", map[string]any{"url": targetURL}, session.SessionID, &nav); err != nil {
+		return nil, err
+	}
+	if nav.ErrorText != "" {
+		return nil, fmt.Errorf("navigation failed: %s", nav.ErrorText)
+	}
+	frameID = nav.FrameID
+
+	for !loaded {
+		if err := cdp.readMessage(); err != nil {
+			return nil, fmt.Errorf("waiting for load event: %w", err)
+		}
+	}
+	fmt.Fprintf(log, "Load event fired\n")
+
+	var eval struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+		ExceptionDetails json.RawMessage `json:"exceptionDetails"`
+	}
+	if err := cdp.call("Runtime.evaluate", map[string]any{
+		"expression":    "document.documentElement.outerHTML",
+		"returnByValue": true,
+	}, session.SessionID, &eval); err != nil {
+		return nil, err
+	}
+	if len(eval.ExceptionDetails) > 0 {
+		return nil, fmt.Errorf("serializing DOM: %s", eval.ExceptionDetails)
+	}
+	page.HTML = eval.Result.Value
+	return page, nil
+}
+
+// resolveBrowserWS turns an HTTP debugging endpoint into the browser
+// WebSocket URL; ws:// and wss:// URLs are returned unchanged
+func resolveBrowserWS(ctx context.Context, endpoint string) (string, error) {
+	if strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://") {
+		return endpoint, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(endpoint, "/")+"/json/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid browser endpoint: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("querying browser endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil || version.WebSocketDebuggerURL == "" {
+		return "", fmt.Errorf("browser endpoint %s did not report a webSocketDebuggerUrl", endpoint)
+	}
+	return version.WebSocketDebuggerURL, nil
+}
+
+// cdpClient issues sequential CDP commands over a single WebSocket
+type cdpClient struct {
+	ws      *wsConn
+	nextID  int
+	pending map[int]*cdpMessage
+	onEvent func(method string, sessionID string, params json.RawMessage)
+}
+
+type cdpMessage struct {
+	ID        int             `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// call sends a command and blocks until its reply arrives, dispatching any
+// events received in the meantime
+func (c *cdpClient) call(method string, params any, sessionID string, out any) error {
+	c.nextID++
+	id := c.nextID
+
+	msg := map[string]any{"id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	if sessionID != "" {
+		msg["sessionId"] = sessionID
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := c.ws.WriteText(payload); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	for {
+		if reply, ok := c.pending[id]; ok {
+			delete(c.pending, id)
+			if reply.Error != nil {
+				return fmt.Errorf("%s: %s (code %d)", method, reply.Error.Message, reply.Error.Code)
+			}
+			if out != nil && len(reply.Result) > 0 {
+				return json.Unmarshal(reply.Result, out)
+			}
+			return nil
+		}
+		if err := c.readMessage(); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+}
+
+// readMessage reads one CDP message, storing replies and dispatching events
+func (c *cdpClient) readMessage() error {
+	data, err := c.ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	var msg cdpMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("decoding CDP message: %w", err)
+	}
+	if msg.ID != 0 {
+		if c.pending == nil {
+			c.pending = make(map[int]*cdpMessage)
+		}
+		c.pending[msg.ID] = &msg
+	} else if msg.Method != "" && c.onEvent != nil {
+		c.onEvent(msg.Method, msg.SessionID, msg.Params)
+	}
+	return nil
+}
+
+// wsConn is a minimal client-side WebSocket connection (RFC 6455)
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialWebSocket performs the opening handshake; the context deadline applies
+// to the whole connection lifetime
+func dialWebSocket(ctx context.Context, rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req := &http.Request{
+		Method: "GET",
+		URL:    &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	accept := sha1.Sum([]byte(key + wsGUID))
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+// WriteText sends payload as a single masked text frame
+func (w *wsConn) WriteText(payload []byte) error {
+	return w.writeFrame(0x1, payload)
+}
+
+func (w *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 0x80|126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	header = append(header, mask...)
+
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	_, err := w.conn.Write(append(header, masked...))
+	return err
+}
+
+// ReadMessage returns the next complete text or binary message, answering
+// pings and reassembling fragmented frames along the way
+func (w *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(w.br, head[:]); err != nil {
+			return nil, err
+		}
+		fin := head[0]&0x80 != 0
+		opcode := head[0] & 0x0F
+		length := uint64(head[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+
+		var mask []byte
+		if head[1]&0x80 != 0 {
+			mask = make([]byte, 4)
+			if _, err := io.ReadFull(w.br, mask); err != nil {
+				return nil, err
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(w.br, payload); err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case 0x8:
+			return nil, errors.New("websocket closed by browser")
+		case 0x9:
+			if err := w.writeFrame(0xA, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case 0xA:
+			continue
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// Close sends a close frame and tears down the connection
+func (w *wsConn) Close() error {
+	w.writeFrame(0x8, nil)
+	return w.conn.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EffectiveRequest is the request actually put on the wire for the final hop
+// of an attempt, as a structured alternative to the dump in DetailedError
+type EffectiveRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Proxy   string            `json:"proxy,omitempty"`
+	Headers map[string]string `json:"headers"` // encoded in sorted key order
+}
+
+// sensitiveHeaders have their values masked in EffectiveRequest
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// effectiveRequest describes req as sent through proxyURL (nil for direct).
+// Besides the caller's headers it includes the ones the client and transport
+// add on their own: Host, the default gzip Accept-Encoding, and the proxy
+// credentials (on the request itself for http targets, on CONNECT for https).
+func effectiveRequest(req *http.Request, proxyURL *url.URL) *EffectiveRequest {
+	headers := flattenHeaders(req.Header)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers["Host"] = host
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != http.MethodHead {
+		headers["Accept-Encoding"] = "gzip"
+	}
+
+	er := &EffectiveRequest{Method: req.Method, URL: req.URL.String(), Headers: headers}
+	if proxyURL != nil {
+		er.Proxy = maskProxy(proxyURL.String())
+		if proxyURL.User != nil && req.Header.Get("Proxy-Authorization") == "" {
+			headers["Proxy-Authorization"] = "Basic ****"
+		}
+	}
+
+	for name, value := range headers {
+		if sensitiveHeaders[name] {
+			headers[name] = maskCredential(value)
+		}
+	}
+	return er
+}
+
+// maskCredential keeps the auth scheme of a header value and hides the rest
+func maskCredential(value string) string {
+	if scheme, _, ok := strings.Cut(value, " "); ok && scheme != "" && !strings.Contains(scheme, "=") {
+		return scheme + " ****"
+	}
+	return "****"
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scrapeRequest is the body accepted by the server's scrape endpoints. Zero
+// values fall back to the flags the server was started with.
+type scrapeRequest struct {
+	URLs       []string `json:"urls"`
+	Proxies    []string `json:"proxies,omitempty"`
+	ProxyType  string   `json:"proxy_type,omitempty"`
+	Timeout    int      `json:"timeout,omitempty"`
+	MaxRetries int      `json:"max_retries,omitempty"`
+}
+
+// runServer serves scrape requests until the listener fails:
+//
+//	POST /scrape         JSON scrapeRequest in, Response out
+//	GET  /scrape/stream  ?urls=a,b&timeout=..., results as Server-Sent Events
+//	POST /scrape/stream  JSON scrapeRequest in, results as Server-Sent Events
+//	GET  /health         liveness check
+//	GET  /metrics        active requests and queue depth
+//
+// The stream emits one "result" event per Result as it completes and a final
+// "done" event carrying the Response summary (without results). Closing the
+// connection cancels the scrape.
+//
+// Both scrape endpoints share the admission limits; when the queue is full
+// they answer 503 with a Retry-After header.
+func runServer(addr string, proxies []string, defaults scrapeConfig, limits *admission) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scrape", limited(limits, func(w http.ResponseWriter, r *http.Request) {
+		handleScrape(w, r, proxies, defaults)
+	}))
+	mux.HandleFunc("/scrape/stream", limited(limits, func(w http.ResponseWriter, r *http.Request) {
+		handleScrapeStream(w, r, proxies, defaults)
+	}))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, struct {
+			serverMetrics
+			goroutineStats
+		}{limits.metrics(), currentGoroutineStats()})
+	})
+
+	logf("Listening on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// retryAfterSeconds is the back-off suggested to clients turned away by the
+// admission limits
+const retryAfterSeconds = 5
+
+// limited runs handler only once the request is admitted
+func limited(limits *admission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, ok := limits.acquire(r.Context())
+		if !ok {
+			if r.Context().Err() != nil {
+				return // client gave up while queued
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server busy, scrape queue is full"})
+			return
+		}
+		defer release()
+		handler(w, r)
+	}
+}
+
+func handleScrape(w http.ResponseWriter, r *http.Request, proxies []string, defaults scrapeConfig) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+
+	targets, reqProxies, cfg, err := parseScrapeRequest(r, proxies, defaults)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	startTime := time.Now()
+	results := scrapeURLs(r.Context(), targets, reqProxies, cfg, nil)
+	writeJSON(w, http.StatusOK, buildResponse(results, time.Since(startTime).Seconds(), cfg.ProxyType))
+}
+
+func handleScrapeStream(w http.ResponseWriter, r *http.Request, proxies []string, defaults scrapeConfig) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET or POST"})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	targets, reqProxies, cfg, err := parseScrapeRequest(r, proxies, defaults)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// r.Context() is cancelled when the client disconnects, which stops the scrape
+	startTime := time.Now()
+	results := scrapeURLs(r.Context(), targets, reqProxies, cfg, func(result Result) {
+		if r.Context().Err() == nil {
+			writeEvent(w, "result", result)
+			flusher.Flush()
+		}
+	})
+	if r.Context().Err() != nil {
+		logf("Stream client disconnected, scrape of %d URLs cancelled", len(targets))
+		return
+	}
+
+	summary := buildResponse(results, time.Since(startTime).Seconds(), cfg.ProxyType)
+	summary.Results = nil
+	writeEvent(w, "done", summary)
+	flusher.Flush()
+}
+
+// parseScrapeRequest reads a scrapeRequest from a JSON body (POST) or from
+// query parameters (GET) and layers it over the server defaults
+func parseScrapeRequest(r *http.Request, proxies []string, defaults scrapeConfig) ([]scrapeTarget, []string, scrapeConfig, error) {
+	var req scrapeRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, nil, defaults, fmt.Errorf("invalid JSON body: %v", err)
+		}
+	} else {
+		var err error
+		if req, err = scrapeRequestFromQuery(r.URL.Query()); err != nil {
+			return nil, nil, defaults, err
+		}
+	}
+
+	var targets []scrapeTarget
+	for _, u := range req.URLs {
+		u = strings.TrimSpace(u)
+		if u != "" {
+			targets = append(targets, scrapeTarget{URL: u})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil, defaults, fmt.Errorf("no URLs provided")
+	}
+	if req.Timeout < 0 || req.MaxRetries < 0 {
+		return nil, nil, defaults, fmt.Errorf("timeout and max_retries cannot be negative")
+	}
+
+	cfg := defaults
+	if req.ProxyType != "" {
+		cfg.ProxyType = req.ProxyType
+	}
+	if req.Timeout > 0 {
+		cfg.Timeout = req.Timeout
+		cfg.TimeoutPinned = true
+	}
+	if req.MaxRetries > 0 {
+		cfg.MaxRetries = req.MaxRetries
+	}
+	if len(req.Proxies) > 0 {
+		proxies = req.Proxies
+	}
+	return targets, proxies, cfg, nil
+}
+
+func scrapeRequestFromQuery(q url.Values) (scrapeRequest, error) {
+	req := scrapeRequest{ProxyType: q.Get("proxy_type")}
+	if v := q.Get("urls"); v != "" {
+		req.URLs = strings.Split(v, ",")
+	}
+	if v := q.Get("proxies"); v != "" {
+		req.Proxies = strings.Split(v, ",")
+	}
+	for name, dst := range map[string]*int{"timeout": &req.Timeout, "max_retries": &req.MaxRetries} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return req, fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*dst = n
+		}
+	}
+	return req, nil
+}
+
+// writeEvent writes one Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(payload)
+}
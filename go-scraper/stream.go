@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lineRecord is one streamed line in -stream-lines output
+type lineRecord struct {
+	URL  string `json:"url"`
+	Line int    `json:"line"`
+	Data string `json:"data"`
+}
+
+// runStreamLines streams every target concurrently and writes NDJSON to
+// stdout: one lineRecord per received line, interleaved across URLs in arrival
+// order, followed by the Response summary (Results without content) as the
+// final line. The results are returned for the -usage-report.
+func runStreamLines(targets []scrapeTarget, proxies []string, cfg scrapeConfig, maxLineBytes int) ([]Result, error) {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+
+	startTime := time.Now()
+	results := make([]Result, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		i, target := i, target
+		stream := func() {
+			defer wg.Done()
+			lineNum := 0
+			results[i] = streamLines(context.Background(), target.URL, proxies, target.apply(cfg), maxLineBytes, func(line []byte) error {
+				lineNum++
+				mu.Lock()
+				defer mu.Unlock()
+				return encoder.Encode(lineRecord{URL: target.URL, Line: lineNum, Data: string(line)})
+			})
+		}
+		wg.Add(1)
+		if !tryGo(stream) {
+			stream() // over -max-goroutines, stream this one inline
+		}
+	}
+	wg.Wait()
+
+	return results, encoder.Encode(buildResponse(results, time.Since(startTime).Seconds(), cfg.ProxyType))
+}
+
+// streamLines fetches targetURL and hands each line of the body to onLine as
+// it arrives, for NDJSON and other line-delimited streaming endpoints.
+//
+// Unlike scrapeURL the body is never buffered: memory stays bounded by
+// maxLineBytes and the first record is delivered as soon as it is received,
+// at the cost of Result.Content staying empty and no retry once streaming has
+// begun (lines already delivered cannot be taken back). Connection failures
+// before the response headers are retried as usual. The -timeout only bounds
+// the wait for response headers so long-lived streams are not cut off; cancel
+// ctx to stop a stream. Returning an error from onLine stops it as well.
+func streamLines(ctx context.Context, targetURL string, proxies []string, cfg scrapeConfig, maxLineBytes int, onLine func(line []byte) error) Result {
+	startTime := time.Now()
+	var detailedErrorBuilder strings.Builder
+	selector := newProxySelector(proxies, cfg.MaxPerProxy)
+	attemptsMade := 0
+	var attempts []AttemptRecord
+	var lastErr error
+
+	for attempt := 0; attempt < cfg.MaxRetries && ctx.Err() == nil; attempt++ {
+		if delay := backoffDelay(attempt, cfg.BackoffBase, cfg.BackoffMax, cfg.JitterFactor); delay > 0 {
+			fmt.Fprintf(&detailedErrorBuilder, "Backing off %s before retry\n", delay)
+			if !sleepContext(ctx, delay) {
+				break
+			}
+		}
+
+		attemptsMade++
+		attemptStartTime := time.Now()
+		var selectedProxy string
+		recordAttempt := func(statusCode int, bytesRead int64, errMsg string, success bool) {
+			attempts = append(attempts, AttemptRecord{
+				URL:            targetURL,
+				Attempt:        attemptsMade,
+				Method:         "GET",
+				StartedAt:      attemptStartTime,
+				ElapsedSeconds: time.Since(attemptStartTime).Seconds(),
+				Proxy:          proxyLabel(selectedProxy),
+				proxyKey:       bareProxy(selectedProxy),
+				StatusCode:     statusCode,
+				BytesRead:      bytesRead,
+				Error:          errMsg,
+				Success:        success,
+			})
+		}
+		fmt.Fprintf(&detailedErrorBuilder, "--- Stream attempt %d/%d at %s ---\n", attempt+1, cfg.MaxRetries, time.Now().Format(time.RFC3339))
+
+		var proxyURL *url.URL
+		var proxyOpts proxyOptions
+		if len(proxies) > 0 {
+			var switched bool
+			selectedProxy, switched = selector.next()
+			if switched {
+				logf("%s: proxy attempt limit of %d reached, switched to %s", targetURL, cfg.MaxPerProxy, maskProxy(selectedProxy))
+			}
+			fmt.Fprintf(&detailedErrorBuilder, "Using proxy: %s\n", maskProxy(selectedProxy))
+			var err error
+			if proxyURL, proxyOpts, err = parseProxy(selectedProxy); err != nil {
+				lastErr = err
+				fmt.Fprintf(&detailedErrorBuilder, "Error parsing proxy URL: %v\n", err)
+				recordAttempt(0, 0, fmt.Sprintf("Error parsing proxy URL: %v", err), false)
+				continue
+			}
+		}
+		client := &http.Client{Transport: clientTransport(proxyURL, targetURL, cfg.HostHeader)}
+
+		dialTimeout := dialTimeoutFor(attempt, cfg.DialTimeout, cfg.DialTimeoutMax)
+		if dialTimeout > 0 {
+			fmt.Fprintf(&detailedErrorBuilder, "Dial timeout: %s\n", dialTimeout)
+		}
+		dialCtx, dial := withDialSettings(ctx, cfg.DialRetries, dialTimeout)
+		attemptCtx, cancel := context.WithCancel(dialCtx)
+		req, err := http.NewRequestWithContext(attemptCtx, "GET", targetURL, nil)
+		if err != nil {
+			cancel()
+			lastErr = err
+			fmt.Fprintf(&detailedErrorBuilder, "Error creating request: %v\n", err)
+			recordAttempt(0, 0, fmt.Sprintf("Error creating request: %v", err), false)
+			continue
+		}
+		req.Header.Set("User-Agent", userAgents[rand.Intn(len(userAgents))])
+		if cfg.HostHeader != "" {
+			req.Host = cfg.HostHeader
+		}
+
+		// Only the wait for headers is bounded by the timeout
+		headerTimer := time.AfterFunc(time.Duration(cfg.timeoutFor(proxyOpts))*time.Second, cancel)
+		resp, err := client.Do(req)
+		headerTimer.Stop()
+		for _, note := range dial.drain() {
+			fmt.Fprintf(&detailedErrorBuilder, "%s\n", note)
+		}
+		if err != nil {
+			cancel()
+			lastErr = err
+			fmt.Fprintf(&detailedErrorBuilder, "Request error: %v\n", err)
+			recordAttempt(0, 0, err.Error(), false)
+			continue
+		}
+		fmt.Fprintf(&detailedErrorBuilder, "Response received with status: %d, streaming lines\n", resp.StatusCode)
+
+		result := Result{
+			URL:             targetURL,
+			StatusCode:      resp.StatusCode,
+			StatusText:      statusText(resp),
+			FinalURL:        resp.Request.URL.String(),
+			ResponseHeaders: flattenHeaders(resp.Header),
+			ProxyUsed:       cfg.ProxyType,
+			AttemptsMade:    attemptsMade,
+			HostHeader:      cfg.HostHeader,
+		}
+
+		lines, streamErr := scanLines(attemptCtx, resp, maxLineBytes, &result.BytesRead, onLine)
+		resp.Body.Close()
+		cancel()
+
+		fmt.Fprintf(&detailedErrorBuilder, "Streamed %d lines (%d bytes) in %s\n", lines, result.BytesRead, time.Since(startTime))
+		if streamErr != nil {
+			fmt.Fprintf(&detailedErrorBuilder, "Stream ended with error: %v\n", streamErr)
+			result.Error = fmt.Sprintf("Stream interrupted after %d lines: %v", lines, streamErr)
+		}
+		result.Success = streamErr == nil && cfg.isSuccess(targetURL, resp.StatusCode)
+		recordAttempt(resp.StatusCode, result.BytesRead, result.Error, result.Success)
+		result.Attempts = attempts
+		result.DetailedError = detailedErrorBuilder.String()
+		result.ElapsedTime = time.Since(startTime).Seconds()
+		return result
+	}
+
+	if ctx.Err() != nil {
+		lastErr = ctx.Err()
+	}
+	return Result{
+		URL:           targetURL,
+		Error:         fmt.Sprintf("All %d stream attempts failed: %v", cfg.MaxRetries, lastErr),
+		DetailedError: detailedErrorBuilder.String(),
+		ElapsedTime:   time.Since(startTime).Seconds(),
+		Success:       false,
+		ProxyUsed:     cfg.ProxyType,
+		AttemptsMade:  attemptsMade,
+		HostHeader:    cfg.HostHeader,
+		Attempts:      attempts,
+	}
+}
+
+// scanLines feeds the body to onLine one line at a time until EOF, an error,
+// or cancellation
+func scanLines(ctx context.Context, resp *http.Response, maxLineBytes int, bytesRead *int64, onLine func(line []byte) error) (int, error) {
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	lines := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return lines, err
+		}
+		line := scanner.Bytes()
+		*bytesRead += int64(len(line)) + 1
+		if len(line) == 0 {
+			continue
+		}
+		lines++
+		if err := onLine(line); err != nil {
+			return lines, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return lines, err
+	}
+	return lines, ctx.Err()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// statusRanges is a set of inclusive HTTP status ranges such as "200-299,404"
+type statusRanges [][2]int
+
+func parseStatusRanges(spec string) (statusRanges, error) {
+	var ranges statusRanges
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		low, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q", part)
+		}
+		high := low
+		if isRange {
+			if high, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || high < low {
+				return nil, fmt.Errorf("invalid status range %q", part)
+			}
+		}
+		ranges = append(ranges, [2]int{low, high})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no statuses in %q", spec)
+	}
+	return ranges, nil
+}
+
+func (r statusRanges) contains(code int) bool {
+	for _, rng := range r {
+		if code >= rng[0] && code <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// successPolicy decides which status codes count as Success. Host rules take
+// precedence over the global ranges.
+type successPolicy struct {
+	global statusRanges
+	hosts  map[string]statusRanges
+}
+
+// newSuccessPolicy parses the -success-statuses and -host-success-statuses flags
+func newSuccessPolicy(globalSpec string, hostSpec string) (*successPolicy, error) {
+	global, err := parseStatusRanges(globalSpec)
+	if err != nil {
+		return nil, fmt.Errorf("-success-statuses: %v", err)
+	}
+	policy := &successPolicy{global: global, hosts: make(map[string]statusRanges)}
+
+	rules, err := parseHostRules(hostSpec)
+	if err != nil {
+		return nil, fmt.Errorf("-host-success-statuses: %v", err)
+	}
+	for host, spec := range rules {
+		ranges, err := parseStatusRanges(spec)
+		if err != nil {
+			return nil, fmt.Errorf("-host-success-statuses %s: %v", host, err)
+		}
+		policy.hosts[host] = ranges
+	}
+	return policy, nil
+}
+
+func (p *successPolicy) isSuccess(targetURL string, statusCode int) bool {
+	if u, err := url.Parse(targetURL); err == nil {
+		if ranges, ok := matchHost(p.hosts, u.Hostname()); ok {
+			return ranges.contains(statusCode)
+		}
+	}
+	return p.global.contains(statusCode)
+}
+
+// parseHostRules parses "host=value;host=value" flag values. Hosts are
+// lowercased; a "*.example.com" key matches any subdomain of example.com.
+func parseHostRules(spec string) (map[string]string, error) {
+	rules := make(map[string]string)
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		host, value, ok := strings.Cut(rule, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid rule %q, expected host=value", rule)
+		}
+		rules[host] = strings.TrimSpace(value)
+	}
+	return rules, nil
+}
+
+// matchHost looks up a host rule: an exact match wins, then the longest
+// matching "*." wildcard
+func matchHost[T any](rules map[string]T, host string) (T, bool) {
+	host = strings.ToLower(host)
+	if v, ok := rules[host]; ok {
+		return v, true
+	}
+	for suffix := host; ; {
+		_, rest, ok := strings.Cut(suffix, ".")
+		if !ok {
+			break
+		}
+		if v, ok := rules["*."+rest]; ok {
+			return v, true
+		}
+		suffix = rest
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// TLSInfo describes the TLS session negotiated with the target server
+type TLSInfo struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipher_suite"`
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+	ServerName         string `json:"server_name,omitempty"`
+
+	// Expiry of the presented chain, judged by its earliest notAfter
+	CertNotAfter     *time.Time `json:"cert_not_after,omitempty"`
+	DaysUntilExpiry  int        `json:"days_until_expiry"`
+	CertExpiringSoon bool       `json:"cert_expiring_soon"`
+}
+
+// CertExpiryWarning summarizes a host whose certificate chain expires within
+// -cert-expiry-warn-days (or already has)
+type CertExpiryWarning struct {
+	Host            string    `json:"host"`
+	NotAfter        time.Time `json:"not_after"`
+	DaysUntilExpiry int       `json:"days_until_expiry"`
+}
+
+// captureTLSInfo extracts the TLS connection state from a response; plain
+// HTTP responses yield nil. NegotiatedProtocol is the ALPN value agreed during
+// the handshake ("h2" or "http/1.1"), empty when the server ignores ALPN.
+// Certificates are inspected even though verification is disabled, so expired
+// chains are still reported.
+func captureTLSInfo(resp *http.Response, warnDays int) *TLSInfo {
+	if resp == nil || resp.TLS == nil {
+		return nil
+	}
+	state := resp.TLS
+	info := &TLSInfo{
+		Version:            tlsVersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+	}
+
+	for _, cert := range state.PeerCertificates {
+		if info.CertNotAfter == nil || cert.NotAfter.Before(*info.CertNotAfter) {
+			notAfter := cert.NotAfter
+			info.CertNotAfter = &notAfter
+		}
+	}
+	if info.CertNotAfter != nil {
+		info.DaysUntilExpiry = int(math.Floor(time.Until(*info.CertNotAfter).Hours() / 24))
+		info.CertExpiringSoon = info.DaysUntilExpiry < warnDays
+	}
+	return info
+}
+
+// certExpiryWarnings lists each host with an expiring chain once, soonest first
+func certExpiryWarnings(results []Result) []CertExpiryWarning {
+	seen := make(map[string]bool)
+	var warnings []CertExpiryWarning
+	for _, result := range results {
+		if result.TLS == nil || !result.TLS.CertExpiringSoon {
+			continue
+		}
+		host := result.TLS.ServerName
+		if u, err := url.Parse(result.FinalURL); err == nil && u.Hostname() != "" {
+			host = u.Hostname()
+		}
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		warnings = append(warnings, CertExpiryWarning{
+			Host:            host,
+			NotAfter:        *result.TLS.CertNotAfter,
+			DaysUntilExpiry: result.TLS.DaysUntilExpiry,
+		})
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].NotAfter.Before(warnings[j].NotAfter)
+	})
+	return warnings
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
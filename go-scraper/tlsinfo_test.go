@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCaptureTLSNegotiatesH2(t *testing.T) {
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	origin.EnableHTTP2 = true
+	origin.StartTLS()
+	defer origin.Close()
+
+	cfg := scrapeConfig{Timeout: 5, MaxRetries: 1, CaptureTLS: true}
+	result := scrapeURL(context.Background(), origin.URL, nil, cfg)
+	if !result.Success {
+		t.Fatalf("scrape failed: %s", result.Error)
+	}
+	if result.TLS == nil {
+		t.Fatal("no TLS info captured")
+	}
+	if got := result.TLS.NegotiatedProtocol; got != "h2" {
+		t.Fatalf("negotiated protocol %q, want h2", got)
+	}
+	if result.Content != "HTTP/2.0" {
+		t.Fatalf("request sent as %q, want HTTP/2.0", result.Content)
+	}
+}
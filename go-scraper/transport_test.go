@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTaggingProxy starts a forward proxy that stamps every response with
+// X-Proxy: tag
+func newTaggingProxy(t *testing.T, tag string) *url.URL {
+	t.Helper()
+	upstream := &http.Transport{}
+	t.Cleanup(upstream.CloseIdleConnections)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outReq := r.Clone(r.Context())
+		outReq.RequestURI = ""
+		resp, err := upstream.RoundTrip(outReq)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("X-Proxy", tag)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestTransportForIsolatesProxies(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer origin.Close()
+
+	proxies := map[string]*url.URL{
+		"A": newTaggingProxy(t, "A"),
+		"B": newTaggingProxy(t, "B"),
+	}
+	if transportFor(proxies["A"], "") == transportFor(proxies["B"], "") {
+		t.Fatal("proxies A and B share a transport")
+	}
+
+	// Interleave so each pool has idle keep-alive connections to reuse
+	for i := 0; i < 20; i++ {
+		for _, tag := range []string{"A", "B", "B", "A"} {
+			client := &http.Client{Transport: transportFor(proxies[tag], "")}
+			resp, err := client.Get(fmt.Sprintf("%s/?i=%d", origin.URL, i))
+			if err != nil {
+				t.Fatalf("request %d via %s: %v", i, tag, err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if got := resp.Header.Get("X-Proxy"); got != tag {
+				t.Fatalf("request %d sent via proxy %s came back via %q", i, tag, got)
+			}
+		}
+	}
+}
+
+func TestTransportForEvictsLeastRecentlyUsed(t *testing.T) {
+	first, _ := url.Parse("http://session-0:pw@proxy.invalid:8000")
+	kept := transportFor(first, "")
+
+	for i := 1; i <= maxPooledTransports; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://session-%d:pw@proxy.invalid:8000", i))
+		transportFor(u, "")
+	}
+
+	transportPool.Lock()
+	size := len(transportPool.transports)
+	transportPool.Unlock()
+	if size > maxPooledTransports {
+		t.Fatalf("pool holds %d transports, want at most %d", size, maxPooledTransports)
+	}
+	if transportFor(first, "") == kept {
+		t.Fatal("least recently used transport was not evicted")
+	}
+}
+
+func TestHostHeaderSNIStaysOnOriginalHost(t *testing.T) {
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.ServerName)
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	var originSNI string
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originSNI = r.TLS.ServerName
+		http.Redirect(w, r, otherURL+"/landing", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	cfg := scrapeConfig{Timeout: 5, MaxRetries: 1, HostHeader: "vhost.example"}
+	result := scrapeURL(context.Background(), origin.URL, nil, cfg)
+	if !result.Success {
+		t.Fatalf("scrape failed: %s", result.Error)
+	}
+	if originSNI != "vhost.example" {
+		t.Fatalf("original host got SNI %q, want vhost.example", originSNI)
+	}
+	if result.Content != "localhost" {
+		t.Fatalf("redirect target got SNI %q, want localhost", result.Content)
+	}
+}
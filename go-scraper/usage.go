@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProxyUsage is one row of the -usage-report, aggregated over every request
+// attempt made through a proxy. Bytes count response bodies as read, after
+// transparent decompression, so provider byte counts that include headers
+// and TLS overhead will run somewhat higher.
+type ProxyUsage struct {
+	Proxy        string  `json:"proxy"`              // password and most of the username masked, "direct" without a proxy
+	ProxyID      string  `json:"proxy_id,omitempty"` // tells apart proxies whose masked labels collide
+	Requests     int     `json:"requests"`
+	Successful   int     `json:"successful"`
+	Failed       int     `json:"failed"`
+	SuccessRate  float64 `json:"success_rate"`
+	Bytes        int64   `json:"bytes"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// proxyUsage aggregates the attempt records of results per proxy, sorted by
+// masked proxy and then proxy ID. Rows are keyed on the full proxy URL, so
+// usernames that mask to the same label still get a row each.
+func proxyUsage(results []Result) []ProxyUsage {
+	byProxy := make(map[string]*ProxyUsage)
+	for _, result := range results {
+		for _, a := range result.Attempts {
+			u, ok := byProxy[a.proxyKey]
+			if !ok {
+				u = &ProxyUsage{Proxy: "direct"}
+				if a.proxyKey != "" {
+					u.Proxy = maskProxyUser(a.Proxy)
+					u.ProxyID = proxyID(a.proxyKey)
+				}
+				byProxy[a.proxyKey] = u
+			}
+			u.Requests++
+			if a.Success {
+				u.Successful++
+			} else {
+				u.Failed++
+			}
+			u.Bytes += a.BytesRead
+			u.TotalSeconds += a.ElapsedSeconds
+		}
+	}
+
+	usage := make([]ProxyUsage, 0, len(byProxy))
+	for _, u := range byProxy {
+		u.SuccessRate = float64(u.Successful) / float64(u.Requests)
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Proxy != usage[j].Proxy {
+			return usage[i].Proxy < usage[j].Proxy
+		}
+		return usage[i].ProxyID < usage[j].ProxyID
+	})
+	return usage
+}
+
+// proxyID is a short one-way digest of the full proxy URL, credentials
+// included, stable across runs so reports can be reconciled
+func proxyID(proxy string) string {
+	sum := sha256.Sum256([]byte(proxy))
+	return hex.EncodeToString(sum[:6])
+}
+
+// maskProxyUser cuts the username of a proxy URL down to a short prefix.
+// Provider usernames often carry the account ID, and usage reports get
+// shared with people who should not see it.
+func maskProxyUser(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	name := u.User.Username()
+	keep := len(name) / 2 // short names would otherwise show in full
+	if keep > 4 {
+		keep = 4
+	}
+	name = name[:keep]
+	userinfo := url.PathEscape(name) + "***"
+	if _, ok := u.User.Password(); ok {
+		userinfo += ":xxxxx"
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "//", "//"+userinfo+"@", 1)
+}
+
+// writeUsageReport writes the per-proxy usage as CSV when path ends in
+// .csv and as JSON otherwise
+func writeUsageReport(path string, results []Result) error {
+	usage := proxyUsage(results)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = writeUsageCSV(f, usage)
+	} else {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(usage)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeUsageCSV(f *os.File, usage []ProxyUsage) error {
+	w := csv.NewWriter(f)
+	w.Write([]string{"proxy", "proxy_id", "requests", "successful", "failed", "success_rate", "bytes", "total_seconds"})
+	for _, u := range usage {
+		w.Write([]string{
+			u.Proxy,
+			u.ProxyID,
+			fmt.Sprint(u.Requests),
+			fmt.Sprint(u.Successful),
+			fmt.Sprint(u.Failed),
+			fmt.Sprintf("%.4f", u.SuccessRate),
+			fmt.Sprint(u.Bytes),
+			fmt.Sprintf("%.3f", u.TotalSeconds),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProxyUsageKeepsCollidingLabelsApart(t *testing.T) {
+	attempt := func(spec string, success bool) AttemptRecord {
+		return AttemptRecord{Proxy: proxyLabel(spec), proxyKey: bareProxy(spec), Success: success}
+	}
+	results := []Result{{Attempts: []AttemptRecord{
+		attempt("http://customer-1:pw@gw:8000#type=residential", true),
+		attempt("http://customer-2:pw@gw:8000", false),
+		attempt("http://customer-1:pw@gw:8000", true),
+		attempt("", true),
+	}}}
+
+	first := proxyUsage(results)
+	if len(first) != 3 {
+		t.Fatalf("got %d rows, want 3: %+v", len(first), first)
+	}
+	for i := 0; i < 20; i++ {
+		again := proxyUsage(results)
+		for j := range first {
+			if again[j] != first[j] {
+				t.Fatalf("row %d differs between runs: %+v vs %+v", j, first[j], again[j])
+			}
+		}
+	}
+
+	var proxied []ProxyUsage
+	for _, u := range first {
+		if strings.Contains(u.Proxy, "customer") || strings.Contains(u.Proxy, "pw") {
+			t.Fatalf("row leaks credentials: %q", u.Proxy)
+		}
+		if u.Proxy != "direct" {
+			proxied = append(proxied, u)
+		}
+	}
+	if len(proxied) != 2 || proxied[0].Proxy != proxied[1].Proxy {
+		t.Fatalf("want two rows under one masked label, got %+v", proxied)
+	}
+	if proxied[0].ProxyID == "" || proxied[0].ProxyID == proxied[1].ProxyID {
+		t.Fatalf("proxy IDs do not tell the rows apart: %+v", proxied)
+	}
+	if proxied[0].Requests+proxied[1].Requests != 3 {
+		t.Fatalf("requests split wrong: %+v", proxied)
+	}
+}